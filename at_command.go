@@ -31,6 +31,11 @@ type ATCommand struct {
 	Fault   []string
 	Timeout int
 	LineEnd bool
+
+	// Data is written after the modem answers with Prompt (">" by default),
+	// as required by commands like AT+CMGS.
+	Prompt string
+	Data   []byte
 }
 
 func NewATCommand(command string) *ATCommand {
@@ -99,3 +104,59 @@ func (atc *ATCommand) GetMeaningfulPart(prefix string) error {
 	atc.Processed = data
 	return nil
 }
+
+// extendedError returns the +CME ERROR or +CMS ERROR line of a complete response
+func extendedError(response string) string {
+	for _, prefix := range []string{"+CME ERROR:", "+CMS ERROR:"} {
+		index := strings.Index(response, prefix)
+
+		if index < 0 {
+			continue
+		}
+
+		end := strings.Index(response[index:], "\r\n")
+
+		if end < 0 {
+			continue
+		}
+
+		return strings.TrimSpace(response[index : index+end])
+	}
+
+	return ""
+}
+
+// linesWithPrefix returns the payload of the response lines starting with prefix
+func linesWithPrefix(response []string, prefix string) []string {
+	lines := make([]string, 0)
+
+	for _, line := range response {
+		if strings.HasPrefix(line, prefix) {
+			lines = append(lines, strings.TrimSpace(strings.TrimPrefix(line, prefix)))
+		}
+	}
+
+	return lines
+}
+
+// splitParams splits a response payload on the commas outside of quotes and
+// removes the quotes from the values
+func splitParams(payload string) []string {
+	params := make([]string, 0)
+	current := strings.Builder{}
+	quoted := false
+
+	for _, r := range payload {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			params = append(params, strings.TrimSpace(current.String()))
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	return append(params, strings.TrimSpace(current.String()))
+}
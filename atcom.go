@@ -8,8 +8,10 @@ package atcom
 import (
 	"context"
 	"errors"
+	"math"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tarm/serial"
@@ -18,6 +20,16 @@ import (
 type Atcom struct {
 	serial SerialModel
 	shell  ShellModel
+
+	// sysfsRoot is the sysfs mount used by the detection, see SetSysfsRoot
+	sysfsRoot string
+
+	// probeUnknown enables probing in DecidePort, see SetProbeUnknown
+	probeUnknown bool
+
+	// open sessions by port name, see session.go
+	mu       sync.Mutex
+	sessions map[string]*session
 }
 
 // Serial Implementation for normal usage
@@ -75,8 +87,10 @@ func NewAtcom(s SerialModel, sh ShellModel) *Atcom {
 	}
 
 	return &Atcom{
-		serial: s,
-		shell:  sh,
+		serial:    s,
+		shell:     sh,
+		sysfsRoot: "/sys",
+		sessions:  make(map[string]*session),
 	}
 }
 
@@ -103,6 +117,11 @@ func (t *Atcom) open(portname string, baudrate int) (port *serial.Port, err erro
 // SendAT sends AT command to modem and returns response
 func (t *Atcom) SendAT(c *ATCommand) *ATCommand {
 
+	// share the port with URC listeners when a session is open
+	if s := t.activeSession(c.SerialAttr.Port); s != nil {
+		return s.sendAT(c)
+	}
+
 	command := c.Command
 	lineEnd := c.LineEnd
 	timeout := c.Timeout
@@ -112,6 +131,11 @@ func (t *Atcom) SendAT(c *ATCommand) *ATCommand {
 	baudrate := c.SerialAttr.Baud
 	responseChan := c.ResponseChan
 	urc := c.Urc
+	prompt := c.Prompt
+
+	if c.Data != nil && prompt == "" {
+		prompt = ">"
+	}
 
 	serialPort, err := t.open(portname, baudrate)
 
@@ -122,8 +146,14 @@ func (t *Atcom) SendAT(c *ATCommand) *ATCommand {
 
 	defer t.serial.Close(serialPort)
 
+	// Commands waiting for a prompt must be terminated with a single CR,
+	// otherwise LF would become the first byte of the data.
 	if lineEnd {
-		command += "\r\n"
+		if c.Data != nil {
+			command += "\r"
+		} else {
+			command += "\r\n"
+		}
 	}
 
 	// If urc is true, do not send command to serial port.
@@ -147,6 +177,7 @@ func (t *Atcom) SendAT(c *ATCommand) *ATCommand {
 	go func(ctx context.Context) {
 		response := ""
 		buf := make([]byte, 1024)
+		dataSent := false
 
 		for {
 			select {
@@ -184,6 +215,16 @@ func (t *Atcom) SendAT(c *ATCommand) *ATCommand {
 							c.ResponseChan <- line
 						}
 
+						// write the payload once the modem asks for it
+						if c.Data != nil && !dataSent && strings.HasPrefix(line, prompt) {
+							if _, err := t.serial.Write(serialPort, c.Data); err != nil {
+								found <- err
+								return
+							}
+							dataSent = true
+							continue
+						}
+
 						if strings.Contains(line, "ERROR") {
 							found <- errors.New(line)
 							break
@@ -218,6 +259,15 @@ func (t *Atcom) SendAT(c *ATCommand) *ATCommand {
 					response += string(buf[:n])
 				}
 
+				// write the payload once the modem asks for it
+				if c.Data != nil && !dataSent && strings.Contains(response, prompt) {
+					if _, err := t.serial.Write(serialPort, c.Data); err != nil {
+						found <- err
+						return
+					}
+					dataSent = true
+				}
+
 				if strings.Contains(response, "\r\nOK\r\n") {
 					lines := strings.Split(response, "\r\n")
 
@@ -266,6 +316,9 @@ func (t *Atcom) SendAT(c *ATCommand) *ATCommand {
 				} else if strings.Contains(response, "\r\nERROR\r\n") {
 					found <- errors.New("modem error")
 					return
+				} else if line := extendedError(response); line != "" {
+					found <- errors.New(line)
+					return
 				}
 			}
 		}
@@ -289,3 +342,26 @@ func (t *Atcom) SendAT(c *ATCommand) *ATCommand {
 		}
 	}
 }
+
+// sendATContext runs SendAT until the command completes or ctx is done. The
+// timeout of the command is limited to the deadline of ctx.
+func (t *Atcom) sendATContext(ctx context.Context, c *ATCommand) *ATCommand {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.Timeout = max(int(math.Ceil(time.Until(deadline).Seconds())), 1)
+	}
+
+	sent := *c
+	result := make(chan *ATCommand, 1)
+
+	go func() {
+		result <- t.SendAT(&sent)
+	}()
+
+	select {
+	case com := <-result:
+		return com
+	case <-ctx.Done():
+		c.Error = ctx.Err()
+		return c
+	}
+}
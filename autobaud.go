@@ -0,0 +1,82 @@
+package atcom
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultBaudRates are the rates tried by Autobaud, most common first
+var DefaultBaudRates = []int{115200, 9600, 57600, 38400, 19200, 230400, 460800, 921600}
+
+// AutobaudOptions configures Autobaud
+type AutobaudOptions struct {
+	Rates []int // rates to try in order, DefaultBaudRates when empty
+	Lock  bool  // store the found rate with AT+IPR so the modem stops autobauding
+}
+
+// autobaudTries is the number of AT commands sent per rate. Modems in
+// autobaud mode need a few of them to synchronize.
+const autobaudTries = 2
+
+// Autobaud finds the baud rate of the modem on a UART like /dev/ttyS0 by
+// sending AT at every rate until it answers OK, and returns the working
+// SerialAttr.
+func (t *Atcom) Autobaud(port string, opts AutobaudOptions) (SerialAttr, error) {
+	if port == "" {
+		return SerialAttr{}, errors.New("serialport is required")
+	}
+
+	if t.activeSession(port) != nil {
+		return SerialAttr{}, errors.New("port is in use by a session")
+	}
+
+	rates := opts.Rates
+	if len(rates) == 0 {
+		rates = DefaultBaudRates
+	}
+
+	for _, rate := range rates {
+		attr := SerialAttr{Port: port, Baud: rate}
+
+		if !t.respondsAt(attr) {
+			continue
+		}
+
+		if opts.Lock {
+			com := NewATCommand(fmt.Sprintf("AT+IPR=%d;&W", rate))
+			com.SerialAttr = attr
+			com = t.SendAT(com)
+
+			if com.Error != nil {
+				return attr, fmt.Errorf("lock baud rate: %w", com.Error)
+			}
+		}
+
+		return attr, nil
+	}
+
+	return SerialAttr{}, fmt.Errorf("no response on %s at any baud rate", port)
+}
+
+// respondsAt reports whether the modem answers AT with a clean OK line on attr
+func (t *Atcom) respondsAt(attr SerialAttr) bool {
+	for i := 0; i < autobaudTries; i++ {
+		com := NewATCommand("AT")
+		com.SerialAttr = attr
+		com.Timeout = 1
+		com = t.SendAT(com)
+
+		if com.Error != nil {
+			continue
+		}
+
+		// noise at a wrong rate may contain OK, but not as a line of its own
+		for _, line := range com.Response {
+			if line == "OK" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,86 @@
+package atcom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// baudModem is a fakeModem that only understands commands at its rate and
+// answers with noise at any other rate
+type baudModem struct {
+	*fakeModem
+
+	tries map[int]int // commands written by baud rate
+}
+
+func newBaudModem(rate int, noise string) *baudModem {
+	m := &baudModem{fakeModem: newFakeModem(), tries: make(map[int]int)}
+
+	m.intercept = func([]byte) bool {
+		m.mu.Lock()
+		baud := m.baud
+		m.tries[baud]++
+		m.mu.Unlock()
+
+		if baud != rate {
+			m.emit(noise)
+			return true
+		}
+		return false
+	}
+
+	return m
+}
+
+func TestAutobaud(t *testing.T) {
+	// noise may contain OK, but not as a line of its own
+	modem := newBaudModem(115200, "\xfe\x00OK\xf0\r\n")
+
+	at := NewAtcom(modem, nil)
+	attr, err := at.Autobaud("/dev/ttyS0", AutobaudOptions{Rates: []int{9600, 115200, 57600}, Lock: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, SerialAttr{Port: "/dev/ttyS0", Baud: 115200}, attr)
+	// AT and AT+IPR at the found rate, 57600 is not tried anymore
+	assert.Equal(t, map[int]int{9600: autobaudTries, 115200: 2}, modem.tries)
+	assert.Contains(t, modem.commands(), "AT+IPR=115200;&W")
+}
+
+func TestAutobaudWithoutLock(t *testing.T) {
+	modem := newBaudModem(9600, "")
+
+	at := NewAtcom(modem, nil)
+	attr, err := at.Autobaud("/dev/ttyS0", AutobaudOptions{Rates: []int{9600}})
+	require.NoError(t, err)
+
+	assert.Equal(t, 9600, attr.Baud)
+	assert.Equal(t, []string{"AT"}, modem.commands())
+}
+
+func TestAutobaudLockFails(t *testing.T) {
+	modem := newBaudModem(9600, "")
+	modem.on("AT+IPR=", "\r\nERROR\r\n")
+
+	at := NewAtcom(modem, nil)
+	attr, err := at.Autobaud("/dev/ttyS0", AutobaudOptions{Rates: []int{9600}, Lock: true})
+	require.Error(t, err)
+
+	assert.Contains(t, err.Error(), "lock baud rate")
+	assert.Equal(t, 9600, attr.Baud)
+}
+
+func TestAutobaudNoResponse(t *testing.T) {
+	// a modem echoing the command without OK does not answer
+	modem := newBaudModem(115200, "")
+	modem.on("AT", "")
+
+	at := NewAtcom(modem, nil)
+	_, err := at.Autobaud("/dev/ttyS0", AutobaudOptions{Rates: []int{115200}})
+	assert.EqualError(t, err, "no response on /dev/ttyS0 at any baud rate")
+	assert.Equal(t, autobaudTries, modem.tries[115200])
+
+	_, err = at.Autobaud("", AutobaudOptions{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,973 @@
+package atcom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// Frame types of 3GPP TS 27.010, without the P/F bit
+const (
+	cmuxSABM byte = 0x2f
+	cmuxUA   byte = 0x63
+	cmuxDM   byte = 0x0f
+	cmuxDISC byte = 0x43
+	cmuxUIH  byte = 0xef
+	cmuxUI   byte = 0x03
+	cmuxPF   byte = 0x10
+)
+
+// Control channel messages, without the EA and C/R bits
+const (
+	cmuxPN    byte = 0x80
+	cmuxPSC   byte = 0x40
+	cmuxCLD   byte = 0xc0
+	cmuxTest  byte = 0x20
+	cmuxFCon  byte = 0xa0
+	cmuxFCoff byte = 0x60
+	cmuxMSC   byte = 0xe0
+	cmuxNSC   byte = 0x10
+	cmuxRPN   byte = 0x90
+	cmuxRLS   byte = 0x50
+	cmuxSNC   byte = 0xd0
+)
+
+// V.24 signals of MSC, ready is DTR and RTS on with valid data
+const (
+	cmuxFC    byte = 0x02
+	cmuxReady byte = 0x8d
+)
+
+const (
+	cmuxBasicFlag    byte = 0xf9
+	cmuxAdvancedFlag byte = 0x7e
+	cmuxEscape       byte = 0x7d
+
+	cmuxFrameSize   = 127
+	cmuxTimeout     = 3 * time.Second
+	cmuxRetries     = 3
+	cmuxReadTimeout = 100 * time.Millisecond // same as a serial port, see Atcom.open
+
+	// a channel asks the modem to pause above cmuxHighWater buffered bytes
+	cmuxHighWater = 64 * 1024
+	cmuxLowWater  = 16 * 1024
+
+	// consecutive unanswered test commands closing the multiplexer
+	cmuxKeepAliveMisses = 3
+)
+
+// AT+CMUX port speed parameter by baud rate
+var cmuxSpeeds = map[int]int{9600: 1, 19200: 2, 38400: 3, 57600: 4, 115200: 5, 230400: 6, 460800: 7, 921600: 8}
+
+// errCMUXChannelClosed is returned by virtual ports of closed channels
+var errCMUXChannelClosed = errors.New("multiplexer channel closed")
+
+// CMUXOptions configures OpenCMUX
+type CMUXOptions struct {
+	Advanced  bool          // advanced option with HDLC transparency instead of the basic option
+	FrameSize int           // maximum data bytes per frame (N1), 127 when zero
+	KeepAlive time.Duration // interval of test commands on the control channel, zero disables them
+}
+
+// CMUX is a 3GPP TS 27.010 multiplexer on a serial port. Its channels are
+// opened with Open.
+//
+// An Atcom created with NewAtcom(mux.Serial(), nil) sends commands and
+// listens for URCs on the virtual port of a channel, e.g. /dev/ttyS0:cmux1,
+// and reaches other ports as usual.
+type CMUX struct {
+	at        *Atcom
+	attr      SerialAttr
+	port      *serial.Port
+	advanced  bool
+	frameSize int
+
+	writeMu sync.Mutex
+
+	mu          sync.Mutex
+	channels    map[int]*CMUXChannel
+	handles     map[*serial.Port]*CMUXChannel // open virtual ports
+	waiters     map[int]chan byte             // UA or DM by DLCI
+	replies     map[byte]chan []byte          // control responses by message type
+	flowOff     bool                          // the modem sent FCoff
+	flowChanged chan struct{}                 // closed when flowOff or a channel's stopped changes
+	err         error
+
+	done chan struct{}
+}
+
+// CMUXChannel is an open DLCI of a multiplexer. It is an io.ReadWriteCloser,
+// e.g. for PPP, and reachable as virtual port, see Port.
+type CMUXChannel struct {
+	mux  *CMUX
+	dlci int
+
+	// guarded by mux.mu
+	buf       []byte
+	stopped   bool // the modem asked to stop sending with MSC
+	throttled bool // we asked the modem to stop sending
+	closed    bool
+
+	notify chan struct{}
+}
+
+// OpenCMUX switches the modem on attr to multiplexer mode with AT+CMUX and
+// opens the control channel. The port cannot be used directly until the
+// multiplexer is closed.
+func (t *Atcom) OpenCMUX(attr SerialAttr, opts CMUXOptions) (*CMUX, error) {
+	if t.activeSession(attr.Port) != nil {
+		return nil, errors.New("port is in use by a session")
+	}
+
+	if opts.FrameSize == 0 {
+		opts.FrameSize = cmuxFrameSize
+	}
+
+	mode := 0
+	if opts.Advanced {
+		mode = 1
+	}
+
+	speed, ok := cmuxSpeeds[attr.Baud]
+	if !ok {
+		speed = cmuxSpeeds[115200]
+	}
+
+	com := NewATCommand(fmt.Sprintf("AT+CMUX=%d,0,%d,%d", mode, speed, opts.FrameSize))
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+
+	if com.Error != nil {
+		return nil, com.Error
+	}
+
+	port, err := t.open(attr.Port, attr.Baud)
+
+	if err != nil {
+		return nil, err
+	}
+
+	m := &CMUX{
+		at:          t,
+		attr:        attr,
+		port:        port,
+		advanced:    opts.Advanced,
+		frameSize:   opts.FrameSize,
+		channels:    make(map[int]*CMUXChannel),
+		handles:     make(map[*serial.Port]*CMUXChannel),
+		waiters:     make(map[int]chan byte),
+		replies:     make(map[byte]chan []byte),
+		flowChanged: make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	go m.readLoop()
+
+	if err := m.request(0, cmuxSABM); err != nil {
+		m.close(err)
+		return nil, err
+	}
+
+	if opts.KeepAlive > 0 {
+		go m.keepAlive(opts.KeepAlive)
+	}
+
+	return m, nil
+}
+
+// Open opens channel dlci (1-63) and reports DTR and RTS on
+func (m *CMUX) Open(dlci int) (*CMUXChannel, error) {
+	if dlci < 1 || dlci > 63 {
+		return nil, fmt.Errorf("invalid dlci: %d", dlci)
+	}
+
+	m.mu.Lock()
+	if m.err != nil {
+		m.mu.Unlock()
+		return nil, m.err
+	}
+
+	if _, ok := m.channels[dlci]; ok {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("dlci %d already open", dlci)
+	}
+
+	c := &CMUXChannel{mux: m, dlci: dlci, notify: make(chan struct{}, 1)}
+	m.channels[dlci] = c
+	m.mu.Unlock()
+
+	err := m.request(dlci, cmuxSABM)
+
+	if err == nil {
+		err = m.signals(dlci, false)
+	}
+
+	if err != nil {
+		m.mu.Lock()
+		delete(m.channels, dlci)
+		m.mu.Unlock()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Close closes the open channels and returns the modem to AT command mode
+func (m *CMUX) Close() error {
+	m.mu.Lock()
+	channels := make([]*CMUXChannel, 0, len(m.channels))
+	for _, c := range m.channels {
+		channels = append(channels, c)
+	}
+	closed := m.err != nil
+	m.mu.Unlock()
+
+	if closed {
+		return nil
+	}
+
+	for _, c := range channels {
+		c.Close()
+	}
+
+	_, err := m.command(cmuxCLD, nil, cmuxTimeout)
+	m.close(errors.New("multiplexer closed"))
+
+	return err
+}
+
+// Done is closed when the multiplexer stops, Err tells why
+func (m *CMUX) Done() <-chan struct{} {
+	return m.done
+}
+
+// Err returns the reason the multiplexer stopped, nil while it runs
+func (m *CMUX) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.err
+}
+
+// cmuxSerial implements SerialModel for the virtual ports of a multiplexer.
+// Other ports are opened by the serial implementation of the Atcom the
+// multiplexer runs on.
+type cmuxSerial struct {
+	m *CMUX
+}
+
+// Serial returns a SerialModel reaching the virtual ports of the channels
+func (m *CMUX) Serial() SerialModel {
+	return &cmuxSerial{m: m}
+}
+
+func (s *cmuxSerial) OpenPort(c *serial.Config) (*serial.Port, error) {
+	m := s.m
+
+	if c.Name == m.attr.Port {
+		return nil, errors.New("port is multiplexed, use its channels")
+	}
+
+	m.mu.Lock()
+	for _, channel := range m.channels {
+		if channel.Port() == c.Name {
+			// the handle only identifies the virtual port
+			handle := &serial.Port{}
+			m.handles[handle] = channel
+			m.mu.Unlock()
+			return handle, nil
+		}
+	}
+	m.mu.Unlock()
+
+	return m.at.serial.OpenPort(c)
+}
+
+func (s *cmuxSerial) Write(port *serial.Port, command []byte) (n int, err error) {
+	if c := s.m.handle(port); c != nil {
+		return c.Write(command)
+	}
+
+	return s.m.at.serial.Write(port, command)
+}
+
+func (s *cmuxSerial) Close(port *serial.Port) (err error) {
+	m := s.m
+
+	m.mu.Lock()
+	_, ok := m.handles[port]
+	delete(m.handles, port)
+	m.mu.Unlock()
+
+	if ok {
+		return nil
+	}
+
+	return m.at.serial.Close(port)
+}
+
+func (s *cmuxSerial) Read(port *serial.Port, buffer []byte) (n int, err error) {
+	if c := s.m.handle(port); c != nil {
+		return c.read(buffer, cmuxReadTimeout)
+	}
+
+	return s.m.at.serial.Read(port, buffer)
+}
+
+// handle returns the channel of a virtual port or nil
+func (m *CMUX) handle(port *serial.Port) *CMUXChannel {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.handles[port]
+}
+
+// Port returns the name of the virtual port of the channel
+func (c *CMUXChannel) Port() string {
+	return fmt.Sprintf("%s:cmux%d", c.mux.attr.Port, c.dlci)
+}
+
+// Read blocks until data arrives on the channel
+func (c *CMUXChannel) Read(p []byte) (int, error) {
+	n, err := c.read(p, 0)
+
+	if err == errCMUXChannelClosed {
+		return n, io.EOF
+	}
+
+	return n, err
+}
+
+// Write sends p in frames of at most the frame size. It waits while the
+// modem has stopped the flow.
+func (c *CMUXChannel) Write(p []byte) (int, error) {
+	written := 0
+
+	for written < len(p) {
+		if err := c.waitFlow(); err != nil {
+			return written, err
+		}
+
+		n := min(len(p)-written, c.mux.frameSize)
+
+		if err := c.mux.writeFrame(c.dlci, cmuxUIH, p[written:written+n]); err != nil {
+			return written, err
+		}
+
+		written += n
+	}
+
+	return written, nil
+}
+
+// Close closes the channel, the multiplexer keeps running
+func (c *CMUXChannel) Close() error {
+	m := c.mux
+
+	m.mu.Lock()
+	if c.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	running := m.err == nil
+	m.mu.Unlock()
+
+	var err error
+	if running {
+		err = m.request(c.dlci, cmuxDISC)
+	}
+
+	m.mu.Lock()
+	c.closeLocked()
+	m.mu.Unlock()
+
+	return err
+}
+
+// closeLocked marks the channel closed, m.mu must be held
+func (c *CMUXChannel) closeLocked() {
+	c.closed = true
+
+	if c.mux.channels[c.dlci] == c {
+		delete(c.mux.channels, c.dlci)
+	}
+
+	c.wake()
+	c.mux.flowUpdated()
+}
+
+// read returns buffered data, waiting up to timeout for it. It returns
+// io.EOF on timeout like a serial port, a zero timeout waits forever.
+func (c *CMUXChannel) read(p []byte, timeout time.Duration) (int, error) {
+	m := c.mux
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		m.mu.Lock()
+		n := copy(p, c.buf)
+		c.buf = c.buf[n:]
+
+		resume := c.throttled && len(c.buf) < cmuxLowWater
+		if resume {
+			c.throttled = false
+		}
+
+		closed, err := c.closed, m.err
+		m.mu.Unlock()
+
+		if resume {
+			m.signals(c.dlci, false)
+		}
+
+		if n > 0 {
+			return n, nil
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		if closed {
+			return 0, errCMUXChannelClosed
+		}
+
+		select {
+		case <-c.notify:
+		case <-m.done:
+		case <-deadline:
+			return 0, io.EOF
+		}
+	}
+}
+
+// waitFlow blocks while the modem does not accept data for the channel
+func (c *CMUXChannel) waitFlow() error {
+	m := c.mux
+
+	for {
+		m.mu.Lock()
+		err, closed, stopped, changed := m.err, c.closed, m.flowOff || c.stopped, m.flowChanged
+		m.mu.Unlock()
+
+		switch {
+		case err != nil:
+			return err
+		case closed:
+			return errCMUXChannelClosed
+		case !stopped:
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-m.done:
+		}
+	}
+}
+
+// wake notifies a waiting read
+func (c *CMUXChannel) wake() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// flowUpdated wakes the writers waiting for the flow, m.mu must be held
+func (m *CMUX) flowUpdated() {
+	close(m.flowChanged)
+	m.flowChanged = make(chan struct{})
+}
+
+// close stops the multiplexer and closes the serial port
+func (m *CMUX) close(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return
+	}
+
+	m.err = err
+	close(m.done)
+	m.at.serial.Close(m.port)
+}
+
+// request sends a SABM or DISC frame and waits for UA
+func (m *CMUX) request(dlci int, ctrl byte) error {
+	wait := make(chan byte, 1)
+
+	m.mu.Lock()
+	m.waiters[dlci] = wait
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.waiters, dlci)
+		m.mu.Unlock()
+	}()
+
+	for i := 0; i < cmuxRetries; i++ {
+		if err := m.writeFrame(dlci, ctrl|cmuxPF, nil); err != nil {
+			return err
+		}
+
+		select {
+		case reply := <-wait:
+			if reply != cmuxUA {
+				return fmt.Errorf("dlci %d: rejected by modem", dlci)
+			}
+			return nil
+		case <-time.After(cmuxTimeout):
+		case <-m.done:
+			return m.Err()
+		}
+	}
+
+	return fmt.Errorf("dlci %d: no response from modem", dlci)
+}
+
+// command sends a control message and returns the values of its response
+func (m *CMUX) command(kind byte, values []byte, timeout time.Duration) ([]byte, error) {
+	wait := make(chan []byte, 1)
+
+	m.mu.Lock()
+	m.replies[kind] = wait
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.replies, kind)
+		m.mu.Unlock()
+	}()
+
+	if err := m.control(kind, true, values); err != nil {
+		return nil, err
+	}
+
+	select {
+	case reply := <-wait:
+		return reply, nil
+	case <-time.After(timeout):
+		return nil, errors.New("no response to multiplexer command")
+	case <-m.done:
+		return nil, m.Err()
+	}
+}
+
+// control sends a control message on DLCI 0
+func (m *CMUX) control(kind byte, command bool, values []byte) error {
+	typ := kind | 0x01
+	if command {
+		typ |= 0x02
+	}
+
+	info := append([]byte{typ, byte(len(values))<<1 | 0x01}, values...)
+
+	return m.writeFrame(0, cmuxUIH, info)
+}
+
+// signals reports the V.24 signals of dlci, stop asks the modem to pause
+func (m *CMUX) signals(dlci int, stop bool) error {
+	v24 := cmuxReady
+	if stop {
+		v24 |= cmuxFC
+	}
+
+	return m.control(cmuxMSC, true, []byte{byte(dlci)<<2 | 0x03, v24})
+}
+
+// keepAlive sends test commands and closes the multiplexer when the modem
+// stops answering
+func (m *CMUX) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	missed := 0
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := m.command(cmuxTest, []byte("atcom"), interval); err != nil {
+			missed++
+
+			if missed >= cmuxKeepAliveMisses {
+				m.close(errors.New("multiplexer keepalive timeout"))
+				return
+			}
+			continue
+		}
+
+		missed = 0
+	}
+}
+
+// writeFrame sends a frame, commands with C/R set as we are the initiator
+func (m *CMUX) writeFrame(dlci int, ctrl byte, info []byte) error {
+	cr := byte(0x02)
+	if kind := ctrl &^ cmuxPF; kind == cmuxUA || kind == cmuxDM {
+		cr = 0
+	}
+
+	frame := m.encode([]byte{byte(dlci)<<2 | cr | 0x01, ctrl}, info)
+
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+
+	_, err := m.at.serial.Write(m.port, frame)
+	return err
+}
+
+// encode builds a basic or advanced option frame from its address and
+// control octets and the information field
+func (m *CMUX) encode(header []byte, info []byte) []byte {
+	uih := header[1]&^cmuxPF == cmuxUIH
+
+	if !m.advanced {
+		if len(info) < 128 {
+			header = append(header, byte(len(info))<<1|0x01)
+		} else {
+			header = append(header, byte(len(info)<<1), byte(len(info)>>7))
+		}
+	}
+
+	// the FCS of UIH frames does not cover the information field
+	fcs := cmuxFCS(header)
+	if !uih {
+		fcs = cmuxFCS(append(append([]byte{}, header...), info...))
+	}
+
+	if !m.advanced {
+		frame := append([]byte{cmuxBasicFlag}, header...)
+		frame = append(frame, info...)
+		return append(frame, fcs, cmuxBasicFlag)
+	}
+
+	body := append(append(append([]byte{}, header...), info...), fcs)
+	frame := []byte{cmuxAdvancedFlag}
+
+	for _, b := range body {
+		switch b {
+		case cmuxAdvancedFlag, cmuxEscape, 0x11, 0x13:
+			frame = append(frame, cmuxEscape, b^0x20)
+		default:
+			frame = append(frame, b)
+		}
+	}
+
+	return append(frame, cmuxAdvancedFlag)
+}
+
+func (m *CMUX) readLoop() {
+	buf := make([]byte, 1024)
+	data := make([]byte, 0)
+
+	for {
+		select {
+		case <-m.done:
+			return
+		default:
+		}
+
+		n, err := m.at.serial.Read(m.port, buf)
+
+		if err != nil {
+			if err.Error() == "EOF" {
+				time.Sleep(time.Millisecond * 5)
+				continue
+			}
+
+			m.close(err)
+			return
+		}
+
+		if m.advanced {
+			data = m.parseAdvanced(append(data, buf[:n]...))
+		} else {
+			data = m.parseBasic(append(data, buf[:n]...))
+		}
+	}
+}
+
+// parseBasic handles the complete basic option frames in data and returns
+// the bytes that have to wait for more input
+func (m *CMUX) parseBasic(data []byte) []byte {
+	for {
+		start := bytes.IndexByte(data, cmuxBasicFlag)
+
+		if start < 0 {
+			return data[:0]
+		}
+
+		// a closing flag may be followed by the opening flag of the next frame
+		data = data[start:]
+		for len(data) > 1 && data[1] == cmuxBasicFlag {
+			data = data[1:]
+		}
+
+		if len(data) < 4 {
+			return data
+		}
+
+		length, header := int(data[3]>>1), 3
+		if data[3]&0x01 == 0 {
+			if len(data) < 5 {
+				return data
+			}
+			length, header = length|int(data[4])<<7, 4
+		}
+
+		if length > m.frameSize {
+			data = data[1:]
+			continue
+		}
+
+		end := 1 + header + length + 1
+
+		if len(data) < end+1 {
+			return data
+		}
+
+		if data[end] != cmuxBasicFlag {
+			data = data[1:]
+			continue
+		}
+
+		addr, ctrl := data[1], data[2]
+		info := data[1+header : 1+header+length]
+
+		covered := data[1 : 1+header]
+		if ctrl&^cmuxPF != cmuxUIH {
+			covered = data[1 : 1+header+length]
+		}
+
+		if cmuxFCS(covered) == data[end-1] {
+			m.handleFrame(addr, ctrl, append([]byte{}, info...))
+		}
+
+		data = data[end:]
+	}
+}
+
+// parseAdvanced handles the complete advanced option frames in data and
+// returns the bytes that have to wait for more input
+func (m *CMUX) parseAdvanced(data []byte) []byte {
+	for {
+		start := bytes.IndexByte(data, cmuxAdvancedFlag)
+
+		if start < 0 {
+			return data[:0]
+		}
+
+		end := bytes.IndexByte(data[start+1:], cmuxAdvancedFlag)
+
+		if end < 0 {
+			return data[start:]
+		}
+
+		escaped := data[start+1 : start+1+end]
+		data = data[start+1+end:]
+
+		frame := make([]byte, 0, len(escaped))
+		for i := 0; i < len(escaped); i++ {
+			if escaped[i] == cmuxEscape && i+1 < len(escaped) {
+				i++
+				frame = append(frame, escaped[i]^0x20)
+				continue
+			}
+			frame = append(frame, escaped[i])
+		}
+
+		if len(frame) < 3 {
+			continue
+		}
+
+		addr, ctrl := frame[0], frame[1]
+		info := frame[2 : len(frame)-1]
+
+		covered := frame[:2]
+		if ctrl&^cmuxPF != cmuxUIH {
+			covered = frame[:len(frame)-1]
+		}
+
+		if cmuxFCS(covered) == frame[len(frame)-1] {
+			m.handleFrame(addr, ctrl, info)
+		}
+	}
+}
+
+// handleFrame handles a frame received from the modem
+func (m *CMUX) handleFrame(addr byte, ctrl byte, info []byte) {
+	dlci := int(addr >> 2)
+
+	switch ctrl &^ cmuxPF {
+	case cmuxUA, cmuxDM:
+		m.mu.Lock()
+		wait := m.waiters[dlci]
+		m.mu.Unlock()
+
+		if wait != nil {
+			select {
+			case wait <- ctrl &^ cmuxPF:
+			default:
+			}
+		}
+	case cmuxDISC:
+		m.writeFrame(dlci, cmuxUA|cmuxPF, nil)
+
+		if dlci == 0 {
+			m.close(errors.New("multiplexer closed by modem"))
+			return
+		}
+
+		m.mu.Lock()
+		if c := m.channels[dlci]; c != nil {
+			c.closeLocked()
+		}
+		m.mu.Unlock()
+	case cmuxSABM:
+		// channels are opened by us only
+		m.writeFrame(dlci, cmuxDM|cmuxPF, nil)
+	case cmuxUIH, cmuxUI:
+		if dlci == 0 {
+			m.handleControl(info)
+			return
+		}
+
+		m.deliver(dlci, info)
+	}
+}
+
+// deliver buffers data received on a channel
+func (m *CMUX) deliver(dlci int, data []byte) {
+	m.mu.Lock()
+	c := m.channels[dlci]
+
+	if c == nil {
+		m.mu.Unlock()
+		return
+	}
+
+	c.buf = append(c.buf, data...)
+
+	throttle := !c.throttled && len(c.buf) > cmuxHighWater
+	if throttle {
+		c.throttled = true
+	}
+	m.mu.Unlock()
+
+	c.wake()
+
+	if throttle {
+		m.signals(dlci, true)
+	}
+}
+
+// handleControl handles a control message received on DLCI 0
+func (m *CMUX) handleControl(info []byte) {
+	if len(info) < 2 {
+		return
+	}
+
+	typ := info[0]
+	kind := typ &^ 0x03
+	command := typ&0x02 != 0
+
+	// the length field may span several octets
+	length, shift, i := 0, 0, 1
+	for i < len(info) {
+		length |= int(info[i]>>1) << shift
+		shift += 7
+		i++
+
+		if info[i-1]&0x01 != 0 {
+			break
+		}
+	}
+
+	if i+length > len(info) {
+		return
+	}
+
+	values := info[i : i+length]
+
+	if !command {
+		m.mu.Lock()
+		wait := m.replies[kind]
+		m.mu.Unlock()
+
+		if wait != nil {
+			select {
+			case wait <- append([]byte{}, values...):
+			default:
+			}
+		}
+		return
+	}
+
+	switch kind {
+	case cmuxMSC:
+		if len(values) >= 2 {
+			m.mu.Lock()
+			if c := m.channels[int(values[0]>>2)]; c != nil {
+				c.stopped = values[1]&cmuxFC != 0
+				m.flowUpdated()
+			}
+			m.mu.Unlock()
+		}
+	case cmuxFCon, cmuxFCoff:
+		m.mu.Lock()
+		m.flowOff = kind == cmuxFCoff
+		m.flowUpdated()
+		m.mu.Unlock()
+	case cmuxCLD:
+		m.control(kind, false, values)
+		m.close(errors.New("multiplexer closed by modem"))
+		return
+	case cmuxTest, cmuxPN, cmuxPSC, cmuxRPN, cmuxRLS, cmuxSNC:
+	default:
+		m.control(cmuxNSC, false, []byte{typ})
+		return
+	}
+
+	// accept the command by echoing its values
+	m.control(kind, false, values)
+}
+
+// cmuxFCS returns the frame check sequence of data, a reversed CRC-8 with
+// the polynomial x^8 + x^2 + x + 1
+func cmuxFCS(data []byte) byte {
+	fcs := byte(0xff)
+
+	for _, b := range data {
+		fcs ^= b
+		for i := 0; i < 8; i++ {
+			if fcs&0x01 != 0 {
+				fcs = fcs>>1 ^ 0xe0
+			} else {
+				fcs >>= 1
+			}
+		}
+	}
+
+	return 0xff - fcs
+}
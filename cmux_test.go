@@ -0,0 +1,461 @@
+package atcom
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCMUX returns a multiplexer without port with channel 1 open
+func newTestCMUX(advanced bool, frameSize int) (*CMUX, *CMUXChannel) {
+	m := &CMUX{
+		advanced:  advanced,
+		frameSize: frameSize,
+		channels:  make(map[int]*CMUXChannel),
+		waiters:   make(map[int]chan byte),
+		replies:   make(map[byte]chan []byte),
+	}
+
+	c := &CMUXChannel{mux: m, dlci: 1, notify: make(chan struct{}, 1)}
+	m.channels[1] = c
+
+	return m, c
+}
+
+func TestCMUXFCS(t *testing.T) {
+	// 27.010 basic option frames as sent by common implementations
+	tests := []struct {
+		name   string
+		header []byte
+		fcs    byte
+	}{
+		{"SABM DLCI 0", []byte{0x03, 0x3f, 0x01}, 0x1c},
+		{"UA DLCI 0", []byte{0x03, 0x73, 0x01}, 0xd7},
+		{"SABM DLCI 1", []byte{0x07, 0x3f, 0x01}, 0xde},
+		{"DISC DLCI 0", []byte{0x03, 0x53, 0x01}, 0xfd},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.fcs, cmuxFCS(tt.header), tt.name)
+
+		// the FCS of a frame followed by its FCS yields the good remainder
+		assert.Equal(t, byte(0xff-0xcf), cmuxFCS(append(tt.header, tt.fcs)), tt.name)
+	}
+}
+
+func TestCMUXEncode(t *testing.T) {
+	m, _ := newTestCMUX(false, cmuxFrameSize)
+
+	assert.Equal(t, []byte{0xf9, 0x03, 0x3f, 0x01, 0x1c, 0xf9}, m.encode([]byte{0x03, cmuxSABM | cmuxPF}, nil))
+	assert.Equal(t, []byte{0xf9, 0x07, 0x3f, 0x01, 0xde, 0xf9}, m.encode([]byte{0x07, cmuxSABM | cmuxPF}, nil))
+
+	// the FCS of UIH frames covers the address, control and length only
+	frame := m.encode([]byte{0x07, cmuxUIH}, []byte("AT\r"))
+	assert.Equal(t, []byte{0xf9, 0x07, 0xef, 0x07, 'A', 'T', '\r', cmuxFCS([]byte{0x07, 0xef, 0x07}), 0xf9}, frame)
+
+	// two length octets from 128 bytes on, 300 is 0x58 0x02 with EA cleared
+	frame = m.encode([]byte{0x07, cmuxUIH}, make([]byte, 300))
+	assert.Equal(t, []byte{0x07, 0xef, 0x58, 0x02}, frame[1:5])
+
+	// advanced option frames have no length and escape flags
+	m.advanced = true
+	frame = m.encode([]byte{0x07, cmuxUIH}, []byte{0x7e, 0x7d, 0x11, 0x41})
+	assert.Equal(t, []byte{0x7e, 0x07, 0xef, 0x7d, 0x5e, 0x7d, 0x5d, 0x7d, 0x31, 0x41}, frame[:10])
+	assert.Equal(t, byte(0x7e), frame[len(frame)-1])
+}
+
+func TestCMUXParse(t *testing.T) {
+	payload := append([]byte("\r\nOK\r\n"), 0xf9, 0x7e, 0x7d, 0x11, 0x13, 0x00)
+	long := bytes.Repeat([]byte("0123456789"), 40)
+
+	for _, advanced := range []bool{false, true} {
+		name := map[bool]string{false: "basic", true: "advanced"}[advanced]
+
+		t.Run(name, func(t *testing.T) {
+			m, c := newTestCMUX(advanced, 1024)
+			parse, flag := m.parseBasic, byte(cmuxBasicFlag)
+			if advanced {
+				parse, flag = m.parseAdvanced, cmuxAdvancedFlag
+			}
+
+			data := append(m.encode([]byte{0x07, cmuxUIH}, payload), m.encode([]byte{0x07, cmuxUIH}, long)...)
+
+			// frames split across reads, the last flag may open the next frame
+			rest := parse(append([]byte{}, data[:7]...))
+			rest = parse(append(rest, data[7:20]...))
+			assert.Equal(t, []byte{flag}, parse(append(rest, data[20:]...)))
+
+			assert.Equal(t, append(append([]byte{}, payload...), long...), c.buf)
+
+			// frames with a wrong FCS are dropped
+			c.buf = nil
+			frame := m.encode([]byte{0x07, cmuxUIH}, []byte("x"))
+			frame[len(frame)-2] ^= 0x01
+			parse(frame)
+			assert.Empty(t, c.buf)
+
+			// UA answers a waiting request
+			ua := make(chan byte, 1)
+			m.waiters[0] = ua
+			parse(m.encode([]byte{0x03, cmuxUA | cmuxPF}, nil))
+
+			select {
+			case kind := <-ua:
+				assert.Equal(t, cmuxUA, kind)
+			default:
+				t.Fatal("no UA")
+			}
+		})
+	}
+}
+
+func TestCMUXParseBasicKnownFrame(t *testing.T) {
+	m, _ := newTestCMUX(false, cmuxFrameSize)
+
+	ua := make(chan byte, 1)
+	m.waiters[0] = ua
+
+	// garbage before the frame and repeated flags are skipped
+	rest := m.parseBasic([]byte{0x00, 0xf9, 0xf9, 0x03, 0x73, 0x01, 0xd7, 0xf9})
+	assert.Equal(t, []byte{0xf9}, rest)
+
+	require.Len(t, ua, 1)
+	assert.Equal(t, cmuxUA, <-ua)
+}
+
+// cmuxFrame is a frame received by cmuxModem
+type cmuxFrame struct {
+	dlci int
+	ctrl byte // without P/F
+	info []byte
+}
+
+// cmuxModem is a fakeModem that answers AT+CMUX and then the basic option
+// frames of the multiplexer. Channels answer AT commands with OK.
+type cmuxModem struct {
+	*fakeModem
+
+	enc    *CMUX // encodes the frames of the modem
+	frames chan cmuxFrame
+
+	// guarded by fakeModem.mu
+	refused     map[int]bool // DLCIs answered with DM
+	ignoreTests bool
+}
+
+func newCMUXModem() *cmuxModem {
+	enc, _ := newTestCMUX(false, cmuxFrameSize)
+	m := &cmuxModem{
+		fakeModem: newFakeModem(),
+		enc:       enc,
+		frames:    make(chan cmuxFrame, 1024),
+		refused:   make(map[int]bool),
+	}
+
+	m.intercept = func(data []byte) bool {
+		if len(data) < 6 || data[0] != cmuxBasicFlag {
+			return false
+		}
+
+		length := int(data[3] >> 1)
+		frame := cmuxFrame{dlci: int(data[1] >> 2), ctrl: data[2] &^ cmuxPF, info: append([]byte{}, data[4:4+length]...)}
+		m.frames <- frame
+		m.answer(frame)
+		return true
+	}
+
+	return m
+}
+
+// answer responds to a frame like a modem
+func (m *cmuxModem) answer(f cmuxFrame) {
+	m.mu.Lock()
+	refused, ignoreTests := m.refused[f.dlci], m.ignoreTests
+	m.mu.Unlock()
+
+	switch {
+	case f.ctrl == cmuxSABM && refused:
+		m.send(f.dlci, cmuxDM|cmuxPF, nil)
+	case f.ctrl == cmuxSABM, f.ctrl == cmuxDISC:
+		m.send(f.dlci, cmuxUA|cmuxPF, nil)
+	case f.ctrl != cmuxUIH:
+	case f.dlci > 0:
+		if bytes.HasPrefix(f.info, []byte("AT")) {
+			m.send(f.dlci, cmuxUIH, []byte("\r\nOK\r\n"))
+		}
+	case f.info[0]&0x02 == 0:
+		// responses to our commands
+	case f.info[0]&^0x03 == cmuxTest && ignoreTests:
+	default:
+		// accept commands by echoing them as response
+		response := append([]byte{f.info[0] &^ 0x02}, f.info[1:]...)
+		m.send(0, cmuxUIH, response)
+	}
+}
+
+// send emits a frame of the modem
+func (m *cmuxModem) send(dlci int, ctrl byte, info []byte) {
+	m.emit(string(m.enc.encode([]byte{byte(dlci)<<2 | 0x01, ctrl}, info)))
+}
+
+// command emits a control command of the modem
+func (m *cmuxModem) command(kind byte, values ...byte) {
+	m.send(0, cmuxUIH, append([]byte{kind | 0x03, byte(len(values))<<1 | 0x01}, values...))
+}
+
+// waitFrame returns the next received frame matching match
+func (m *cmuxModem) waitFrame(t *testing.T, match func(f cmuxFrame) bool) cmuxFrame {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+
+	for {
+		select {
+		case f := <-m.frames:
+			if match(f) {
+				return f
+			}
+		case <-timeout:
+			t.Fatal("frame not received")
+			return cmuxFrame{}
+		}
+	}
+}
+
+// isControl matches the control messages of kind sent as command
+func isControl(kind byte) func(f cmuxFrame) bool {
+	return func(f cmuxFrame) bool {
+		return f.dlci == 0 && f.ctrl == cmuxUIH && f.info[0] == kind|0x03
+	}
+}
+
+func openTestCMUX(t *testing.T, modem *cmuxModem, opts CMUXOptions) *CMUX {
+	at := NewAtcom(modem, nil)
+	mux, err := at.OpenCMUX(SerialAttr{Port: "/dev/ttyS0", Baud: 115200}, opts)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { mux.Close() })
+	return mux
+}
+
+func TestCMUXOpenClose(t *testing.T) {
+	modem := newCMUXModem()
+	mux := openTestCMUX(t, modem, CMUXOptions{})
+
+	assert.Contains(t, modem.commands(), "AT+CMUX=0,0,5,127")
+	modem.waitFrame(t, func(f cmuxFrame) bool { return f.dlci == 0 && f.ctrl == cmuxSABM })
+
+	c, err := mux.Open(1)
+	require.NoError(t, err)
+	assert.Equal(t, "/dev/ttyS0:cmux1", c.Port())
+
+	modem.waitFrame(t, func(f cmuxFrame) bool { return f.dlci == 1 && f.ctrl == cmuxSABM })
+	msc := modem.waitFrame(t, isControl(cmuxMSC))
+	assert.Equal(t, []byte{cmuxMSC | 0x03, 0x05, 1<<2 | 0x03, cmuxReady}, msc.info)
+
+	_, err = mux.Open(1)
+	assert.Error(t, err)
+
+	// AT commands reach the modem on the virtual port of the channel
+	com := NewATCommand("ATI")
+	com.SerialAttr = SerialAttr{Port: c.Port()}
+	com = NewAtcom(mux.Serial(), nil).SendAT(com)
+	require.NoError(t, com.Error)
+
+	data := modem.waitFrame(t, func(f cmuxFrame) bool { return f.dlci == 1 && f.ctrl == cmuxUIH })
+	assert.Equal(t, "ATI\r\n", string(data.info))
+
+	require.NoError(t, c.Close())
+	modem.waitFrame(t, func(f cmuxFrame) bool { return f.dlci == 1 && f.ctrl == cmuxDISC })
+
+	_, err = c.Write([]byte("AT\r"))
+	assert.Error(t, err)
+
+	require.NoError(t, mux.Close())
+	modem.waitFrame(t, isControl(cmuxCLD))
+
+	<-mux.Done()
+	assert.EqualError(t, mux.Err(), "multiplexer closed")
+
+	_, err = mux.Open(2)
+	assert.Error(t, err)
+}
+
+func TestCMUXOpenFailure(t *testing.T) {
+	modem := newCMUXModem()
+	mux := openTestCMUX(t, modem, CMUXOptions{})
+
+	modem.mu.Lock()
+	modem.refused[2] = true
+	modem.mu.Unlock()
+
+	_, err := mux.Open(2)
+	assert.Error(t, err)
+
+	// a failed MSC does not leave the channel registered
+	modem.fail = func(data []byte) error {
+		if len(data) > 4 && data[1] == 0x03 && data[4] == cmuxMSC|0x03 {
+			return errors.New("write failed")
+		}
+		return nil
+	}
+
+	_, err = mux.Open(3)
+	assert.EqualError(t, err, "write failed")
+
+	mux.mu.Lock()
+	assert.Empty(t, mux.channels)
+	mux.mu.Unlock()
+
+	modem.fail = nil
+
+	_, err = mux.Open(3)
+	assert.NoError(t, err)
+}
+
+func TestCMUXFlowControl(t *testing.T) {
+	modem := newCMUXModem()
+	mux := openTestCMUX(t, modem, CMUXOptions{})
+
+	c, err := mux.Open(1)
+	require.NoError(t, err)
+
+	written := make(chan error, 1)
+	write := func() {
+		go func() {
+			_, err := c.Write([]byte("AT\r"))
+			written <- err
+		}()
+	}
+	assertBlocked := func() {
+		select {
+		case err := <-written:
+			t.Fatalf("write not blocked: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	assertWritten := func() {
+		select {
+		case err := <-written:
+			require.NoError(t, err)
+		case <-time.After(2 * time.Second):
+			t.Fatal("write still blocked")
+		}
+	}
+
+	// FCoff stops every channel, the command is answered
+	modem.command(cmuxFCoff)
+	modem.waitFrame(t, func(f cmuxFrame) bool { return f.dlci == 0 && f.ctrl == cmuxUIH && f.info[0] == cmuxFCoff|0x01 })
+
+	write()
+	assertBlocked()
+
+	modem.command(cmuxFCon)
+	assertWritten()
+
+	// MSC with FC stops a single channel
+	modem.command(cmuxMSC, 1<<2|0x03, cmuxReady|cmuxFC)
+	modem.waitFrame(t, func(f cmuxFrame) bool { return f.dlci == 0 && f.ctrl == cmuxUIH && f.info[0] == cmuxMSC|0x01 })
+
+	write()
+	assertBlocked()
+
+	modem.command(cmuxMSC, 1<<2|0x03, cmuxReady)
+	assertWritten()
+}
+
+func TestCMUXThrottle(t *testing.T) {
+	modem := newCMUXModem()
+	mux := openTestCMUX(t, modem, CMUXOptions{})
+
+	c, err := mux.Open(1)
+	require.NoError(t, err)
+	modem.waitFrame(t, isControl(cmuxMSC))
+
+	// the modem is asked to pause above the high water mark
+	chunk := bytes.Repeat([]byte("x"), cmuxFrameSize)
+	for sent := 0; sent <= cmuxHighWater; sent += len(chunk) {
+		modem.send(1, cmuxUIH, chunk)
+	}
+
+	msc := modem.waitFrame(t, isControl(cmuxMSC))
+	assert.Equal(t, cmuxReady|cmuxFC, msc.info[3])
+
+	// and to resume once the buffer drained below the low water mark
+	buf := make([]byte, 2*cmuxHighWater)
+	read := 0
+	for read <= cmuxHighWater {
+		n, err := c.Read(buf)
+		require.NoError(t, err)
+		read += n
+	}
+
+	msc = modem.waitFrame(t, isControl(cmuxMSC))
+	assert.Equal(t, cmuxReady, msc.info[3])
+}
+
+func TestCMUXKeepAlive(t *testing.T) {
+	modem := newCMUXModem()
+	mux := openTestCMUX(t, modem, CMUXOptions{KeepAlive: 20 * time.Millisecond})
+
+	modem.waitFrame(t, isControl(cmuxTest))
+
+	select {
+	case <-mux.Done():
+		t.Fatal("multiplexer closed although the modem answers")
+	case <-time.After(150 * time.Millisecond):
+	}
+
+	modem.mu.Lock()
+	modem.ignoreTests = true
+	modem.mu.Unlock()
+
+	select {
+	case <-mux.Done():
+		assert.EqualError(t, mux.Err(), "multiplexer keepalive timeout")
+	case <-time.After(2 * time.Second):
+		t.Fatal("multiplexer still open")
+	}
+}
+
+func TestCMUXClosedByModem(t *testing.T) {
+	modem := newCMUXModem()
+	mux := openTestCMUX(t, modem, CMUXOptions{})
+
+	c, err := mux.Open(1)
+	require.NoError(t, err)
+
+	// DISC closes a channel and is answered with UA
+	modem.send(1, cmuxDISC|cmuxPF, nil)
+	modem.waitFrame(t, func(f cmuxFrame) bool { return f.dlci == 1 && f.ctrl == cmuxUA })
+
+	_, err = c.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+
+	// CLD closes the multiplexer and is answered
+	modem.command(cmuxCLD)
+	modem.waitFrame(t, func(f cmuxFrame) bool { return f.dlci == 0 && f.ctrl == cmuxUIH && f.info[0] == cmuxCLD|0x01 })
+
+	select {
+	case <-mux.Done():
+		assert.EqualError(t, mux.Err(), "multiplexer closed by modem")
+	case <-time.After(2 * time.Second):
+		t.Fatal("multiplexer still open")
+	}
+
+	// so does DISC on the control channel
+	modem = newCMUXModem()
+	mux = openTestCMUX(t, modem, CMUXOptions{})
+	modem.send(0, cmuxDISC|cmuxPF, nil)
+
+	select {
+	case <-mux.Done():
+		assert.EqualError(t, mux.Err(), "multiplexer closed by modem")
+	case <-time.After(2 * time.Second):
+		t.Fatal("multiplexer still open")
+	}
+}
@@ -2,10 +2,78 @@ package atcom
 
 import (
 	"errors"
+	"regexp"
+	"sort"
 	"strings"
 )
 
+// PortRole is the purpose of a serial port of a modem
+type PortRole string
+
+const (
+	RoleUnknown PortRole = "unknown"
+	RoleAT      PortRole = "at"    // main AT port
+	RoleAT2     PortRole = "at2"   // additional AT port, e.g. for URCs
+	RoleNMEA    PortRole = "nmea"  // GNSS sentences
+	RoleDIAG    PortRole = "diag"  // vendor diagnostics
+	RoleModem   PortRole = "modem" // PPP data calls, usually accepts AT as well
+	RoleQMI     PortRole = "qmi"   // QMI control of PCIe modems
+	RoleMBIM    PortRole = "mbim"  // MBIM control of PCIe modems
+)
+
+// DetectedPort is a serial port of a detected modem
+type DetectedPort struct {
+	Port      string // e.g. /dev/ttyUSB2
+	Interface string // USB interface, e.g. if02, or WWAN port name, e.g. at0
+	Type      string // WWAN port type of the kernel, e.g. AT or MBIM, empty for USB ports
+	Role      PortRole
+}
+
+// DetectedModem is a supported modem found on the USB or PCI bus
+type DetectedModem struct {
+	VID     string
+	PID     string
+	Vendor  string
+	Product string
+	USBPath string // sysfs path of the USB or PCI device
+	Serial  string // USB serial number, empty when the modem has none
+
+	// Port is the AT port of the modem
+	Port string
+
+	// Interfaces lists every serial port of the modem by interface number
+	Interfaces []DetectedPort
+
+	// Ports holds the serial ports with a known role, e.g. Ports[RoleNMEA]
+	Ports map[PortRole]string
+}
+
+// ErrATPortMissing is returned when a supported modem is attached but its
+// AT port does not exist, e.g. because the serial driver is not loaded
+var ErrATPortMissing = errors.New("modem found but AT port missing")
+
+// getAvailablePorts lists the USB serial ports from sysfs, or with udevadm
+// when sysfs is not readable, followed by the WWAN ports of PCIe modems
 func (t *Atcom) getAvailablePorts() (availablePorts []map[string]string, err error) {
+	availablePorts, err = t.sysfsPorts()
+
+	if err != nil {
+		availablePorts, err = t.shellPorts()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ports, err := t.wwanPorts(); err == nil {
+		availablePorts = append(availablePorts, ports...)
+	}
+
+	return availablePorts, nil
+}
+
+// shellPorts lists the USB serial ports with find and udevadm
+func (t *Atcom) shellPorts() (availablePorts []map[string]string, err error) {
 	output, err := t.shell.Command("bash", "-c", "/usr/bin/find /sys/bus/usb/devices/usb*/ -name dev")
 
 	if err != nil {
@@ -52,6 +120,10 @@ func (t *Atcom) getAvailablePorts() (availablePorts []map[string]string, err err
 				portDetails["ID_USB_VENDOR_ID"] = strings.Trim(line[17:], "'")
 			case strings.HasPrefix(line, "ID_USB_MODEL_ID="):
 				portDetails["ID_USB_MODEL_ID"] = strings.Trim(line[16:], "'")
+			case strings.HasPrefix(line, "ID_SERIAL_SHORT="):
+				portDetails["serial"] = strings.Trim(line[16:], "'")
+			case strings.HasPrefix(line, "DEVPATH="):
+				portDetails["path"] = usbDevicePath(strings.Trim(line[8:], "'"))
 			}
 		}
 
@@ -62,7 +134,41 @@ func (t *Atcom) getAvailablePorts() (availablePorts []map[string]string, err err
 	return availablePorts, nil
 }
 
+// usbInterface matches USB interfaces, named <device>:<configuration>.<interface>.
+// PCI devices like pci0000:00 contain colons as well.
+var usbInterface = regexp.MustCompile(`^\d+-[\d.]+:\d+\.\d+$`)
+
+// usbDevicePath returns the USB device part of the sysfs path of a tty, e.g.
+// /devices/platform/scb/usb1/1-1/1-1.3 for .../1-1.3/1-1.3:1.2/ttyUSB2/tty/ttyUSB2
+func usbDevicePath(devpath string) string {
+	parts := strings.Split(devpath, "/")
+
+	for i, part := range parts {
+		if usbInterface.MatchString(part) {
+			return strings.Join(parts[:i], "/")
+		}
+	}
+
+	return devpath
+}
+
 func (t *Atcom) findModem(smodems []SupportedModem) (SupportedModem, error) {
+	if devices, err := t.usbDevices(); err == nil {
+		if pci, err := t.pciDevices(); err == nil {
+			devices = append(devices, pci...)
+		}
+
+		for _, modem := range smodems {
+			for _, device := range devices {
+				if device.vid == modem.VID && device.pid == modem.PID {
+					return modem, nil
+				}
+			}
+		}
+
+		return SupportedModem{}, errors.New("no supported modem found")
+	}
+
 	output, err := t.shell.Command("lsusb")
 
 	if err != nil {
@@ -71,7 +177,7 @@ func (t *Atcom) findModem(smodems []SupportedModem) (SupportedModem, error) {
 
 	for _, modem := range smodems {
 		for _, line := range strings.Split(output, "\n") {
-			if strings.Contains(line, modem.vid) && strings.Contains(line, modem.pid) {
+			if strings.Contains(line, modem.VID) && strings.Contains(line, modem.PID) {
 				return modem, nil
 			}
 		}
@@ -80,10 +186,17 @@ func (t *Atcom) findModem(smodems []SupportedModem) (SupportedModem, error) {
 	return SupportedModem{}, errors.New("no supported modem found")
 }
 
-func (t *Atcom) DecidePort() (map[string]string, error) {
-	modem, err := t.findModem(supportedModems)
+// DecidePort detects the first supported modem and its AT port. When the
+// modem is found without its AT port, the modem is returned together with
+// ErrATPortMissing. Unknown modems are found when probing is enabled with
+// SetProbeUnknown.
+func (t *Atcom) DecidePort() (*DetectedModem, error) {
+	modem, err := t.findModem(SupportedModems())
 
 	if err != nil {
+		if t.probeUnknown {
+			return t.probeModem()
+		}
 		return nil, err
 	}
 
@@ -93,22 +206,60 @@ func (t *Atcom) DecidePort() (map[string]string, error) {
 		return nil, err
 	}
 
+	return detectedModem(modem, ports)
+}
+
+// detectedModem collects the ports of modem. The ports of the first USB
+// device of the modem are used when several are attached.
+func detectedModem(modem SupportedModem, ports []map[string]string) (*DetectedModem, error) {
+	detected := &DetectedModem{
+		VID:     modem.VID,
+		PID:     modem.PID,
+		Vendor:  modem.Vendor,
+		Product: modem.Product,
+		Ports:   make(map[PortRole]string),
+	}
+
 	for _, port := range ports {
-		if port["vendor_id"] == modem.vid &&
-			port["product_id"] == modem.pid &&
-			port["interface"] == modem.ifs {
-
-			detectedModem := map[string]string{
-				"port":   port["port"],
-				"vid":    port["vendor_id"],
-				"pid":    port["product_id"],
-				"vendor": port["vendor"],
-				"model":  port["model"],
-			}
+		if port["vendor_id"] != modem.VID || port["product_id"] != modem.PID {
+			continue
+		}
 
-			return detectedModem, nil
+		if len(detected.Interfaces) == 0 {
+			detected.USBPath = port["path"]
+			detected.Serial = port["serial"]
+		} else if port["path"] != detected.USBPath {
+			continue
 		}
+
+		role := modem.role(port["interface"])
+
+		// WWAN ports missing in the modem table have a type
+		if typeRole, ok := wwanTypeRoles[port["type"]]; ok && role == RoleUnknown {
+			role = typeRole
+		}
+
+		if role != RoleUnknown {
+			detected.Ports[role] = port["port"]
+		}
+
+		detected.Interfaces = append(detected.Interfaces, DetectedPort{
+			Port:      port["port"],
+			Interface: port["interface"],
+			Type:      port["type"],
+			Role:      role,
+		})
+	}
+
+	sort.Slice(detected.Interfaces, func(i, j int) bool {
+		return detected.Interfaces[i].Interface < detected.Interfaces[j].Interface
+	})
+
+	detected.Port = detected.Ports[RoleAT]
+
+	if detected.Port == "" {
+		return detected, ErrATPortMissing
 	}
 
-	return nil, nil
+	return detected, nil
 }
@@ -0,0 +1,107 @@
+package atcom
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUSBDevicePath(t *testing.T) {
+	tests := []struct {
+		devpath string
+		path    string
+	}{
+		{
+			devpath: "/devices/platform/scb/fd500000.pcie/pci0000:00/0000:00:00.0/0000:01:00.0/usb1/1-1/1-1.3/1-1.3:1.2/ttyUSB2/tty/ttyUSB2",
+			path:    "/devices/platform/scb/fd500000.pcie/pci0000:00/0000:00:00.0/0000:01:00.0/usb1/1-1/1-1.3",
+		},
+		{
+			devpath: "/devices/pci0000:00/0000:00:14.0/usb1/1-1/1-1:1.2/ttyUSB2/tty/ttyUSB2",
+			path:    "/devices/pci0000:00/0000:00:14.0/usb1/1-1",
+		},
+		{
+			devpath: "/devices/pci0000:00/0000:00:14.0/usb2/2-1/2-1.4.2/2-1.4.2:1.10/tty/ttyACM0",
+			path:    "/devices/pci0000:00/0000:00:14.0/usb2/2-1/2-1.4.2",
+		},
+		{
+			devpath: "/devices/virtual/tty/ttyS0",
+			path:    "/devices/virtual/tty/ttyS0",
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.path, usbDevicePath(tt.devpath), tt.devpath)
+	}
+}
+
+func TestDecidePort(t *testing.T) {
+	at := NewAtcom(nil, nil)
+	at.SetSysfsRoot(fakeSysfs(t))
+
+	modem, err := at.DecidePort()
+	require.NoError(t, err)
+
+	assert.Equal(t, &DetectedModem{
+		VID:     "2c7c",
+		PID:     "0125",
+		Vendor:  "Quectel",
+		Product: "EC25",
+		USBPath: "/devices/platform/scb/usb1/1-1",
+		Serial:  "0123456789",
+		Port:    "/dev/ttyUSB2",
+		Interfaces: []DetectedPort{
+			{Port: "/dev/ttyACM0", Interface: "if00", Role: RoleDIAG},
+			{Port: "/dev/ttyUSB2", Interface: "if02", Role: RoleAT},
+			{Port: "/dev/ttyUSB3", Interface: "if03", Role: RoleModem},
+		},
+		Ports: map[PortRole]string{
+			RoleDIAG:  "/dev/ttyACM0",
+			RoleAT:    "/dev/ttyUSB2",
+			RoleModem: "/dev/ttyUSB3",
+		},
+	}, modem)
+}
+
+func TestDecidePortATPortMissing(t *testing.T) {
+	root := fakeSysfs(t)
+	require.NoError(t, os.Remove(filepath.Join(root, "class", "tty", "ttyUSB2")))
+
+	at := NewAtcom(nil, nil)
+	at.SetSysfsRoot(root)
+
+	modem, err := at.DecidePort()
+	assert.ErrorIs(t, err, ErrATPortMissing)
+
+	// the modem is still reported with its other ports
+	require.NotNil(t, modem)
+	assert.Equal(t, "EC25", modem.Product)
+	assert.Empty(t, modem.Port)
+	assert.Len(t, modem.Interfaces, 2)
+	assert.Equal(t, "/dev/ttyUSB3", modem.Ports[RoleModem])
+}
+
+func TestDetectedModemFirstDevice(t *testing.T) {
+	modem := SupportedModem{VID: "17cb", PID: "0308", Vendor: "Quectel", Product: "RM520N PCIe", ATInterface: "at0", Roles: wwanRoles}
+	port := func(name string, iface string, kind string, path string) map[string]string {
+		return map[string]string{"port": name, "interface": iface, "type": kind, "vendor_id": "17cb", "product_id": "0308", "path": path}
+	}
+
+	detected, err := detectedModem(modem, []map[string]string{
+		port("/dev/wwan0at0", "at0", "AT", "/devices/pci0000:00/0000:01:00.0"),
+		port("/dev/wwan0xmm0", "xmm0", "XMMRPC", "/devices/pci0000:00/0000:01:00.0"),
+		port("/dev/wwan0firehose0", "firehose0", "FIREHOSE", "/devices/pci0000:00/0000:01:00.0"),
+		// a second modem of the same type
+		port("/dev/wwan1at0", "at0", "AT", "/devices/pci0000:00/0000:02:00.0"),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "/dev/wwan0at0", detected.Port)
+	assert.Equal(t, "/devices/pci0000:00/0000:01:00.0", detected.USBPath)
+	assert.Len(t, detected.Interfaces, 3)
+
+	// ports missing in the roles get theirs from the port type
+	assert.Equal(t, "/dev/wwan0firehose0", detected.Ports[RoleDIAG])
+}
@@ -21,7 +21,7 @@ func main() {
 
 	// Echo Off
 	com = atcom.NewATCommand("ATE0")
-	com.SerialAttr.Port = detected["port"]
+	com.SerialAttr.Port = detected.Port
 	com = at.SendAT(com)
 
 	if com.Error != nil {
@@ -33,7 +33,7 @@ func main() {
 
 	// CGSN
 	com = atcom.NewATCommand("AT+CGSN")
-	com.SerialAttr.Port = detected["port"]
+	com.SerialAttr.Port = detected.Port
 	com = at.SendAT(com)
 	com.GetMeaningfulPart("")
 
@@ -53,7 +53,7 @@ func main() {
 
 	// COPS
 	com = atcom.NewATCommand("AT+COPS?")
-	com.SerialAttr.Port = detected["port"]
+	com.SerialAttr.Port = detected.Port
 	com = at.SendAT(com)
 	com.GetMeaningfulPart("+COPS: ")
 
@@ -73,7 +73,7 @@ func main() {
 
 	// CCID
 	com = atcom.NewATCommand("AT+CCID")
-	com.SerialAttr.Port = detected["port"]
+	com.SerialAttr.Port = detected.Port
 	com = at.SendAT(com)
 	com.GetMeaningfulPart("+CCID: ")
 
@@ -0,0 +1,384 @@
+package atcom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GNSSConstellation is a set of satellite systems
+type GNSSConstellation int
+
+const (
+	GNSSGPS GNSSConstellation = 1 << iota
+	GNSSGLONASS
+	GNSSGalileo
+	GNSSBeiDou
+	GNSSQZSS
+)
+
+// GNSS output ports of the NMEA sentences
+const (
+	GNSSOutputUSB  = "usbnmea"  // NMEA port of the USB interface
+	GNSSOutputUART = "uartnmea" // UART of the module
+	GNSSOutputNone = "none"
+)
+
+// GNSSConfig configures the GNSS receiver, zero values are left unchanged
+type GNSSConfig struct {
+	Constellations GNSSConstellation
+	OutputPort     string
+}
+
+// Fix is a position reported by the GNSS receiver
+type Fix struct {
+	Latitude   float64 // degrees, negative in the south
+	Longitude  float64 // degrees, negative in the west
+	Altitude   float64 // meters above sea level
+	HDOP       float64
+	Speed      float64 // km/h
+	Course     float64 // degrees from true north
+	Time       time.Time
+	Satellites int
+	Mode       int // 2 for 2D, 3 for 3D fixes, 0 without fix
+}
+
+// ErrNoFix is returned while the receiver has no position
+var ErrNoFix = errors.New("no gnss fix")
+
+// quectelConstellations maps the values of AT+QGPSCFG="gnssconfig"
+var quectelConstellations = map[GNSSConstellation]int{
+	GNSSGPS: 0,
+	GNSSGPS | GNSSGLONASS | GNSSBeiDou | GNSSGalileo: 1,
+	GNSSGPS | GNSSGLONASS | GNSSBeiDou:               2,
+	GNSSGPS | GNSSGLONASS | GNSSGalileo:              3,
+	GNSSGPS | GNSSGLONASS:                            4,
+	GNSSGPS | GNSSBeiDou | GNSSGalileo:               5,
+	GNSSGPS | GNSSGalileo:                            6,
+	GNSSBeiDou:                                       7,
+}
+
+// telitConstellations maps the values of AT$GPSCFG=2
+var telitConstellations = map[GNSSConstellation]int{
+	GNSSGPS | GNSSGLONASS: 1,
+	GNSSGPS | GNSSGalileo: 2,
+	GNSSGPS | GNSSBeiDou:  3,
+	GNSSGPS | GNSSQZSS:    4,
+}
+
+// gnssCommands sends commands to attr, stopping at the first error
+func (t *Atcom) gnssCommands(attr SerialAttr, commands ...string) error {
+	for _, command := range commands {
+		com := NewATCommand(command)
+		com.SerialAttr = attr
+		com = t.SendAT(com)
+
+		if com.Error != nil {
+			return com.Error
+		}
+	}
+
+	return nil
+}
+
+// EnableGNSS switches on the GNSS receiver with the command of the vendor
+func (t *Atcom) EnableGNSS(attr SerialAttr) error {
+	vendor, err := t.vendor(attr)
+
+	if err != nil {
+		return err
+	}
+
+	switch vendor {
+	case "Quectel":
+		err = t.gnssCommands(attr, "AT+QGPS=1")
+
+		// CME error 504 means the session is already running
+		if err != nil && strings.HasSuffix(err.Error(), ": 504") {
+			return nil
+		}
+		return err
+	case "Telit":
+		return t.gnssCommands(attr, "AT$GPSP=1")
+	case "Thales/Cinterion":
+		return t.gnssCommands(attr, "AT^SGPSC=\"Engine\",\"1\"")
+	}
+
+	return fmt.Errorf("gnss is not supported for %s", vendor)
+}
+
+// DisableGNSS switches off the GNSS receiver
+func (t *Atcom) DisableGNSS(attr SerialAttr) error {
+	vendor, err := t.vendor(attr)
+
+	if err != nil {
+		return err
+	}
+
+	switch vendor {
+	case "Quectel":
+		err = t.gnssCommands(attr, "AT+QGPSEND")
+
+		// CME error 505 means the session is not active
+		if err != nil && strings.HasSuffix(err.Error(), ": 505") {
+			return nil
+		}
+		return err
+	case "Telit":
+		return t.gnssCommands(attr, "AT$GPSP=0")
+	case "Thales/Cinterion":
+		return t.gnssCommands(attr, "AT^SGPSC=\"Engine\",\"0\"")
+	}
+
+	return fmt.Errorf("gnss is not supported for %s", vendor)
+}
+
+// ConfigureGNSS selects the constellations and the NMEA output port. Some
+// modules only accept it while the receiver is off.
+func (t *Atcom) ConfigureGNSS(attr SerialAttr, config GNSSConfig) error {
+	vendor, err := t.vendor(attr)
+
+	if err != nil {
+		return err
+	}
+
+	commands := make([]string, 0)
+
+	switch vendor {
+	case "Quectel":
+		if config.Constellations != 0 {
+			value, ok := quectelConstellations[config.Constellations]
+
+			if !ok {
+				return errors.New("unsupported constellation combination")
+			}
+			commands = append(commands, fmt.Sprintf("AT+QGPSCFG=\"gnssconfig\",%d", value))
+		}
+
+		if config.OutputPort != "" {
+			commands = append(commands, fmt.Sprintf("AT+QGPSCFG=\"outport\",\"%s\"", config.OutputPort))
+		}
+	case "Telit":
+		if config.Constellations != 0 {
+			value, ok := telitConstellations[config.Constellations]
+
+			if !ok {
+				return errors.New("unsupported constellation combination")
+			}
+			commands = append(commands, fmt.Sprintf("AT$GPSCFG=2,%d", value))
+		}
+
+		if config.OutputPort != "" {
+			return errors.New("gnss output port can not be selected on Telit modules")
+		}
+	case "Thales/Cinterion":
+		if config.Constellations != 0 {
+			if config.Constellations&GNSSGPS == 0 {
+				return errors.New("unsupported constellation combination")
+			}
+
+			for _, system := range []struct {
+				name          string
+				constellation GNSSConstellation
+			}{
+				{"Glonass", GNSSGLONASS},
+				{"Galileo", GNSSGalileo},
+				{"Beidou", GNSSBeiDou},
+			} {
+				state := "off"
+				if config.Constellations&system.constellation != 0 {
+					state = "on"
+				}
+				commands = append(commands, fmt.Sprintf("AT^SGPSC=\"Nmea/%s\",\"%s\"", system.name, state))
+			}
+		}
+
+		switch config.OutputPort {
+		case "":
+		case GNSSOutputNone:
+			commands = append(commands, "AT^SGPSC=\"Nmea/Output\",\"off\"")
+		default:
+			commands = append(commands, "AT^SGPSC=\"Nmea/Output\",\"on\"")
+		}
+	default:
+		return fmt.Errorf("gnss is not supported for %s", vendor)
+	}
+
+	return t.gnssCommands(attr, commands...)
+}
+
+// GNSSFix reads the current position with AT+QGPSLOC or AT$GPSACP. ErrNoFix
+// is returned while there is no position.
+func (t *Atcom) GNSSFix(attr SerialAttr) (Fix, error) {
+	vendor, err := t.vendor(attr)
+
+	if err != nil {
+		return Fix{}, err
+	}
+
+	return t.gnssFix(attr, vendor)
+}
+
+// gnssFix reads the current position with the command of vendor
+func (t *Atcom) gnssFix(attr SerialAttr, vendor string) (Fix, error) {
+	command, prefix := "AT+QGPSLOC=2", "+QGPSLOC:"
+
+	switch vendor {
+	case "Quectel":
+	case "Telit":
+		command, prefix = "AT$GPSACP", "$GPSACP:"
+	default:
+		return Fix{}, fmt.Errorf("gnss position is not supported for %s, read the NMEA port", vendor)
+	}
+
+	com := NewATCommand(command)
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+
+	if com.Error != nil {
+		// CME error 516 means there is no fix yet
+		if strings.HasSuffix(com.Error.Error(), ": 516") {
+			return Fix{}, ErrNoFix
+		}
+		return Fix{}, com.Error
+	}
+
+	for _, line := range linesWithPrefix(com.Response, prefix) {
+		return parseGNSSLocation(line)
+	}
+
+	return Fix{}, errors.New("no position in response")
+}
+
+// parseGNSSLocation parses the payload of +QGPSLOC (mode 2) or $GPSACP:
+// <utc>,<lat>,<lon>,<hdop>,<alt>,<fix>,<cog>,<spkm>,<spkn>,<date>,<nsat>
+func parseGNSSLocation(payload string) (Fix, error) {
+	params := splitParams(payload)
+
+	if len(params) < 11 {
+		return Fix{}, fmt.Errorf("invalid position: %s", payload)
+	}
+
+	fix := Fix{}
+	fix.Mode, _ = strconv.Atoi(params[5])
+
+	// $GPSACP reports empty fields or a mode below 2 without fix
+	if params[1] == "" || fix.Mode < 2 {
+		return Fix{}, ErrNoFix
+	}
+
+	var err error
+
+	if fix.Latitude, err = parseGNSSCoordinate(params[1]); err != nil {
+		return Fix{}, err
+	}
+
+	if fix.Longitude, err = parseGNSSCoordinate(params[2]); err != nil {
+		return Fix{}, err
+	}
+
+	fix.HDOP, _ = strconv.ParseFloat(params[3], 64)
+	fix.Altitude, _ = strconv.ParseFloat(params[4], 64)
+	fix.Course = parseCourse(params[6])
+	fix.Speed, _ = strconv.ParseFloat(params[7], 64)
+	fix.Time, _ = parseNMEATime(params[9], params[0])
+
+	// $GPSACP reports GPS and GLONASS satellites separately
+	for _, count := range params[10:] {
+		n, _ := strconv.Atoi(count)
+		fix.Satellites += n
+	}
+
+	return fix, nil
+}
+
+// parseGNSSCoordinate parses degrees given as decimal degrees (-12.345678)
+// or as NMEA degrees and minutes with hemisphere (4807.0380N)
+func parseGNSSCoordinate(value string) (float64, error) {
+	if value == "" {
+		return 0, errors.New("empty coordinate")
+	}
+
+	hemisphere := value[len(value)-1]
+
+	if !strings.ContainsRune("NSEW", rune(hemisphere)) {
+		return strconv.ParseFloat(value, 64)
+	}
+
+	return parseNMEACoordinate(value[:len(value)-1], string(hemisphere))
+}
+
+// parseNMEACoordinate converts dddmm.mmmm and its hemisphere to degrees
+func parseNMEACoordinate(value string, hemisphere string) (float64, error) {
+	number, err := strconv.ParseFloat(value, 64)
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid coordinate: %s", value)
+	}
+
+	degrees := math.Floor(number / 100)
+	degrees += (number - degrees*100) / 60
+
+	if hemisphere == "S" || hemisphere == "W" {
+		degrees = -degrees
+	}
+
+	return degrees, nil
+}
+
+// parseCourse parses the course, given as ddd.mm (degrees and minutes)
+func parseCourse(value string) float64 {
+	number, err := strconv.ParseFloat(value, 64)
+
+	if err != nil {
+		return 0
+	}
+
+	degrees := math.Floor(number)
+	return degrees + (number-degrees)*100/60
+}
+
+// parseNMEATime combines a ddmmyy date and a hhmmss.sss time in UTC
+func parseNMEATime(date string, clock string) (time.Time, error) {
+	if len(clock) < 6 {
+		return time.Time{}, fmt.Errorf("invalid time: %s", clock)
+	}
+
+	layout := "150405"
+	if len(clock) > 7 && clock[6] == '.' {
+		layout += "." + strings.Repeat("0", len(clock)-7)
+	}
+
+	if date == "" {
+		return time.ParseInLocation(layout, clock, time.UTC)
+	}
+
+	return time.ParseInLocation("020106"+layout, date+clock, time.UTC)
+}
+
+// WaitForFix polls the position until the receiver has a fix or ctx is done
+func (t *Atcom) WaitForFix(ctx context.Context, attr SerialAttr) (Fix, error) {
+	vendor, err := t.vendor(attr)
+
+	if err != nil {
+		return Fix{}, err
+	}
+
+	for {
+		fix, err := t.gnssFix(attr, vendor)
+
+		if err != ErrNoFix {
+			return fix, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return Fix{}, ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
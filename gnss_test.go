@@ -0,0 +1,110 @@
+package atcom
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGNSSLocation(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		fix     Fix
+	}{
+		{
+			name:    "QGPSLOC decimal degrees",
+			payload: "092204.0,31.16539,-121.37824,1.2,26.0,3,183.30,0.4,0.2,110513,07",
+			fix: Fix{
+				Latitude:   31.16539,
+				Longitude:  -121.37824,
+				Altitude:   26,
+				HDOP:       1.2,
+				Speed:      0.4,
+				Course:     183.5,
+				Time:       time.Date(2013, 5, 11, 9, 22, 4, 0, time.UTC),
+				Satellites: 7,
+				Mode:       3,
+			},
+		},
+		{
+			name:    "GPSACP degrees and minutes",
+			payload: "080220.479,4542.82691N,01344.26820W,2.1,259.07,2,0.00,12.5,6.7,270705,05,03",
+			fix: Fix{
+				Latitude:   45.713782,
+				Longitude:  -13.737803,
+				Altitude:   259.07,
+				HDOP:       2.1,
+				Speed:      12.5,
+				Time:       time.Date(2005, 7, 27, 8, 2, 20, 479e6, time.UTC),
+				Satellites: 8,
+				Mode:       2,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fix, err := parseGNSSLocation(tt.payload)
+			require.NoError(t, err)
+
+			assert.InDelta(t, tt.fix.Latitude, fix.Latitude, 1e-6)
+			assert.InDelta(t, tt.fix.Longitude, fix.Longitude, 1e-6)
+			assert.InDelta(t, tt.fix.Course, fix.Course, 1e-6)
+
+			fix.Latitude, fix.Longitude, fix.Course = tt.fix.Latitude, tt.fix.Longitude, tt.fix.Course
+			assert.Equal(t, tt.fix, fix)
+		})
+	}
+}
+
+func TestParseGNSSLocationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		err     error // nil for any error
+	}{
+		{"GPSACP without fix", "002120.000,,,,,1,,,,,00,00", ErrNoFix},
+		{"mode below 2", "092204.0,31.16539,121.37824,1.0,26.0,1,0.00,0.0,0.0,110513,04", ErrNoFix},
+		{"too short", "092204.0,31.16539,121.37824", nil},
+		{"invalid coordinate", "092204.0,abcN,121.37824,1.0,26.0,3,0.00,0.0,0.0,110513,04", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseGNSSLocation(tt.payload)
+
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestWaitForFixLooksUpVendorOnce(t *testing.T) {
+	modem := newFakeModem()
+	modem.on("AT+CGMI", "\r\nQuectel\r\n\r\nOK\r\n")
+
+	// the first poll has no fix yet
+	polls := 0
+	modem.onFunc("AT+QGPSLOC=2", func(string) string {
+		polls++
+		if polls == 1 {
+			return "\r\n+CME ERROR: 516\r\n"
+		}
+		return "\r\n+QGPSLOC: 092204.0,31.16539,121.37824,1.0,26.0,3,0.00,0.0,0.0,110513,04\r\n\r\nOK\r\n"
+	})
+
+	at := NewAtcom(modem, nil)
+	fix, err := at.WaitForFix(context.Background(), SerialAttr{Port: "/dev/ttyUSB2"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, fix.Mode)
+	assert.Equal(t, 2, polls)
+	assert.Equal(t, 1, countCommand(modem.commands(), "AT+CGMI"))
+}
@@ -0,0 +1,157 @@
+package atcom
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sixfab/atcomv2/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/tarm/serial"
+)
+
+// fakeModem is a scripted modem behind mocks.MockSerial. It echoes commands
+// like a modem with ATE1 and answers every write with the reply of the most
+// recently registered matching prefix, OK for unknown commands. Like closed
+// ports, ports reopened meanwhile read nothing.
+type fakeModem struct {
+	*mocks.MockSerial
+
+	mu      sync.Mutex
+	out     []byte
+	written []string
+	replies []fakeReply
+	ports   map[string]*serial.Port // most recently opened port by name
+	names   map[*serial.Port]string
+	baud    int // baud rate of the most recently opened port
+
+	// intercept handles a write instead of the replies when it returns true
+	intercept func(data []byte) bool
+	// fail returns the error of a write, if any
+	fail func(data []byte) error
+}
+
+type fakeReply struct {
+	prefix string
+	reply  func(written string) string
+}
+
+func newFakeModem() *fakeModem {
+	m := &fakeModem{
+		MockSerial: &mocks.MockSerial{},
+		ports:      make(map[string]*serial.Port),
+		names:      make(map[*serial.Port]string),
+	}
+
+	m.On("OpenPort", mock.Anything).Return(func(config *serial.Config) *serial.Port {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		port := &serial.Port{}
+		m.ports[config.Name] = port
+		m.names[port] = config.Name
+		m.baud = config.Baud
+		return port
+	}, nil)
+	m.On("Close", mock.Anything).Return(nil)
+	m.On("Write", mock.Anything, mock.Anything).Return(func(_ *serial.Port, data []byte) int {
+		if m.intercept == nil || !m.intercept(data) {
+			m.write(string(data))
+		}
+		return len(data)
+	}, func(_ *serial.Port, data []byte) error {
+		if m.fail != nil {
+			return m.fail(data)
+		}
+		return nil
+	})
+	m.On("Read", mock.Anything, mock.Anything).Return(func(port *serial.Port, buffer []byte) int {
+		return m.read(port, buffer)
+	}, nil)
+
+	return m
+}
+
+// on answers writes starting with prefix with reply
+func (m *fakeModem) on(prefix string, reply string) {
+	m.onFunc(prefix, func(string) string {
+		return reply
+	})
+}
+
+// onFunc answers writes starting with prefix with the result of fn
+func (m *fakeModem) onFunc(prefix string, fn func(written string) string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.replies = append(m.replies, fakeReply{prefix: prefix, reply: fn})
+}
+
+// emit queues output of the modem, e.g. a URC
+func (m *fakeModem) emit(data string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.out = append(m.out, data...)
+}
+
+// commands returns everything written to the modem, without line ends
+func (m *fakeModem) commands() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	commands := make([]string, 0, len(m.written))
+	for _, written := range m.written {
+		commands = append(commands, strings.TrimRight(written, "\r\n"))
+	}
+
+	return commands
+}
+
+func (m *fakeModem) write(data string) {
+	m.mu.Lock()
+	m.written = append(m.written, data)
+
+	var reply func(string) string
+	for i := len(m.replies) - 1; i >= 0; i-- {
+		if strings.HasPrefix(data, m.replies[i].prefix) {
+			reply = m.replies[i].reply
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	output := ""
+
+	if strings.HasPrefix(strings.ToUpper(data), "AT") {
+		output = strings.TrimRight(data, "\r\n") + "\r\n"
+
+		if reply == nil {
+			output += "\r\nOK\r\n"
+		}
+	}
+
+	if reply != nil {
+		output += reply(data)
+	}
+
+	m.emit(output)
+}
+
+// read returns queued output, waiting a little like a serial port with a
+// read timeout when there is none
+func (m *fakeModem) read(port *serial.Port, buffer []byte) int {
+	m.mu.Lock()
+	n := 0
+	if m.ports[m.names[port]] == port {
+		n = copy(buffer, m.out)
+		m.out = m.out[n:]
+	}
+	m.mu.Unlock()
+
+	if n == 0 {
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	return n
+}
@@ -1,38 +1,185 @@
 package atcom
 
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SupportedModem describes a modem known to the detection
 type SupportedModem struct {
-	vid     string
-	pid     string
-	vendor  string
-	product string
-	ifs     string
+	VID         string `yaml:"vid" json:"vid"`
+	PID         string `yaml:"pid" json:"pid"`
+	Vendor      string `yaml:"vendor" json:"vendor"`
+	Product     string `yaml:"product" json:"product"`
+	ATInterface string `yaml:"at_interface" json:"at_interface"` // USB interface of the AT port, e.g. if02, or WWAN port, e.g. at0
+
+	// Roles of the other serial interfaces by USB interface, ATInterface is
+	// always the AT port
+	Roles map[string]PortRole `yaml:"roles,omitempty" json:"roles,omitempty"`
 }
 
+// Port roles by USB interface, shared by the compositions of a family
+var (
+	quectelRoles = map[string]PortRole{"if00": RoleDIAG, "if01": RoleNMEA, "if03": RoleModem}
+
+	// DIAG, ADB, RMNET, NMEA, MODEM, MODEM, SAP
+	telitRMNETRoles = map[string]PortRole{"if00": RoleDIAG, "if03": RoleNMEA, "if05": RoleAT2}
+	// RNDIS, ECM or MBIM take the first two interfaces
+	telitNetRoles = map[string]PortRole{"if02": RoleDIAG, "if04": RoleNMEA, "if06": RoleAT2}
+	// DIAG, MODEM, MODEM
+	telitME910Roles = map[string]PortRole{"if00": RoleDIAG, "if02": RoleAT2}
+
+	thalesRoles = map[string]PortRole{"if00": RoleModem, "if02": RoleAT2}
+
+	// PCIe modems by WWAN port name, see wwanPorts
+	wwanRoles = map[string]PortRole{"at1": RoleAT2, "nmea0": RoleNMEA, "qcdm0": RoleDIAG, "qmi0": RoleQMI, "mbim0": RoleMBIM}
+)
+
 var supportedModems = []SupportedModem{
 	// Quectel
-	{"2c7c", "0125", "Quectel", "EC25", "if02"},
-	{"2c7c", "0121", "Quectel", "EC21", "if02"},
-	{"2c7c", "0296", "Quectel", "BG96", "if02"},
-	{"2c7c", "0700", "Quectel", "BG95", "if02"},
-	{"2c7c", "0306", "Quectel", "EP06", "if02"},
-	{"2c7c", "0800", "Quectel", "RM5XXQ", "if02"},
+	{"2c7c", "0125", "Quectel", "EC25", "if02", quectelRoles},
+	{"2c7c", "0121", "Quectel", "EC21", "if02", quectelRoles},
+	{"2c7c", "0296", "Quectel", "BG96", "if02", quectelRoles},
+	{"2c7c", "0700", "Quectel", "BG95", "if02", quectelRoles},
+	{"2c7c", "0306", "Quectel", "EP06", "if02", quectelRoles},
+	{"2c7c", "0800", "Quectel", "RM5XXQ", "if02", quectelRoles},
+	// Quectel PCIe, ports in /sys/class/wwan
+	{"17cb", "0306", "Quectel", "RM5XXQ PCIe", "at0", wwanRoles},
+	{"17cb", "0308", "Quectel", "RM520N PCIe", "at0", wwanRoles},
+	{"1eac", "1007", "Quectel", "RM520N PCIe", "at0", wwanRoles},
 	// Telit
-	{"1bc7", "1201", "Telit", "LE910Cx RMNET", "if04"},
-	{"1bc7", "1203", "Telit", "LE910Cx RNDIS", "if05"},
-	{"1bc7", "1204", "Telit", "LE910Cx MBIM", "if05"},
-	{"1bc7", "1206", "Telit", "LE910Cx ECM", "if05"},
-	{"1bc7", "1031", "Telit", "LE910Cx ThreadX RMNET", "if02"},
-	{"1bc7", "1033", "Telit", "LE910Cx ThreadX ECM", "if02"},
-	{"1bc7", "1034", "Telit", "LE910Cx ThreadX RMNET", "if00"},
-	{"1bc7", "1035", "Telit", "LE910Cx ThreadX ECM", "if00"},
-	{"1bc7", "1036", "Telit", "LE910Cx ThreadX OPTION ONLY", "if00"},
-	{"1bc7", "1101", "Telit", "ME910C1", "if01"},
-	{"1bc7", "1102", "Telit", "ME910C1", "if01"},
-	{"1bc7", "1052", "Telit", "FN980 RNDIS", "if05"},
-	{"1bc7", "1050", "Telit", "FN980 RMNET", "if04"},
-	{"1bc7", "1051", "Telit", "FN980 MBIM", "if05"},
-	{"1bc7", "1053", "Telit", "FN980 ECM", "if05"},
+	{"1bc7", "1201", "Telit", "LE910Cx RMNET", "if04", telitRMNETRoles},
+	{"1bc7", "1203", "Telit", "LE910Cx RNDIS", "if05", telitNetRoles},
+	{"1bc7", "1204", "Telit", "LE910Cx MBIM", "if05", telitNetRoles},
+	{"1bc7", "1206", "Telit", "LE910Cx ECM", "if05", telitNetRoles},
+	{"1bc7", "1031", "Telit", "LE910Cx ThreadX RMNET", "if02", nil},
+	{"1bc7", "1033", "Telit", "LE910Cx ThreadX ECM", "if02", nil},
+	{"1bc7", "1034", "Telit", "LE910Cx ThreadX RMNET", "if00", nil},
+	{"1bc7", "1035", "Telit", "LE910Cx ThreadX ECM", "if00", nil},
+	{"1bc7", "1036", "Telit", "LE910Cx ThreadX OPTION ONLY", "if00", nil},
+	{"1bc7", "1101", "Telit", "ME910C1", "if01", telitME910Roles},
+	{"1bc7", "1102", "Telit", "ME910C1", "if01", telitME910Roles},
+	{"1bc7", "1052", "Telit", "FN980 RNDIS", "if05", telitNetRoles},
+	{"1bc7", "1050", "Telit", "FN980 RMNET", "if04", telitRMNETRoles},
+	{"1bc7", "1051", "Telit", "FN980 MBIM", "if05", telitNetRoles},
+	{"1bc7", "1053", "Telit", "FN980 ECM", "if05", telitNetRoles},
 	// Thales
-	{"1e2d", "0069", "Thales/Cinterion", "PLSx3", "if04"},
-	{"1e2d", "006f", "Thales/Cinterion", "PLSx3", "if04"},
+	{"1e2d", "0069", "Thales/Cinterion", "PLSx3", "if04", thalesRoles},
+	{"1e2d", "006f", "Thales/Cinterion", "PLSx3", "if04", thalesRoles},
+}
+
+// modemsMu guards supportedModems against RegisterModem and LoadModems
+var modemsMu sync.RWMutex
+
+// SupportedModems returns a copy of the modem table used by the detection
+func SupportedModems() []SupportedModem {
+	modemsMu.RLock()
+	defer modemsMu.RUnlock()
+
+	modems := make([]SupportedModem, 0, len(supportedModems))
+	for _, modem := range supportedModems {
+		modems = append(modems, modem.copy())
+	}
+
+	return modems
+}
+
+// copy returns m with its own Roles map
+func (m SupportedModem) copy() SupportedModem {
+	if m.Roles != nil {
+		roles := make(map[string]PortRole, len(m.Roles))
+		for ifs, role := range m.Roles {
+			roles[ifs] = role
+		}
+		m.Roles = roles
+	}
+
+	return m
+}
+
+// RegisterModem adds m to the modem table. An entry with the same VID and
+// PID is replaced. When ATInterface is empty, the interface with RoleAT in
+// Roles is used, which must be unique.
+func RegisterModem(m SupportedModem) error {
+	m = m.copy()
+	m.VID = strings.ToLower(m.VID)
+	m.PID = strings.ToLower(m.PID)
+
+	if m.ATInterface == "" {
+		for ifs, role := range m.Roles {
+			if role != RoleAT {
+				continue
+			}
+
+			if m.ATInterface != "" {
+				return fmt.Errorf("modem %s %s: several interfaces have the at role", m.Vendor, m.Product)
+			}
+			m.ATInterface = ifs
+		}
+	}
+
+	if m.VID == "" || m.PID == "" || m.ATInterface == "" {
+		return fmt.Errorf("modem %s %s: vid, pid and at interface are required", m.Vendor, m.Product)
+	}
+
+	modemsMu.Lock()
+	defer modemsMu.Unlock()
+
+	for i, modem := range supportedModems {
+		if modem.VID == m.VID && modem.PID == m.PID {
+			supportedModems[i] = m
+			return nil
+		}
+	}
+
+	supportedModems = append(supportedModems, m)
+	return nil
+}
+
+// LoadModems registers the modems listed in a YAML or JSON file. Every
+// entry has the fields vid, pid, vendor, product, at_interface and roles,
+// e.g. roles: {if00: diag, if01: nmea, if03: modem}.
+func LoadModems(path string) error {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	// JSON is valid YAML
+	modems := make([]SupportedModem, 0)
+
+	if err := yaml.Unmarshal(data, &modems); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if len(modems) == 0 {
+		return errors.New(path + ": no modems defined")
+	}
+
+	for _, modem := range modems {
+		if err := RegisterModem(modem); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// role returns the role of the serial port on USB interface ifs
+func (m SupportedModem) role(ifs string) PortRole {
+	if ifs == m.ATInterface {
+		return RoleAT
+	}
+
+	if role, ok := m.Roles[ifs]; ok {
+		return role
+	}
+
+	return RoleUnknown
 }
@@ -0,0 +1,131 @@
+package atcom
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// restoreModems restores the modem table when the test ends
+func restoreModems(t *testing.T) {
+	modems := SupportedModems()
+
+	t.Cleanup(func() {
+		modemsMu.Lock()
+		supportedModems = modems
+		modemsMu.Unlock()
+	})
+}
+
+// findSupportedModem returns the entry of vid and pid in the modem table
+func findSupportedModem(vid string, pid string) (SupportedModem, bool) {
+	for _, modem := range SupportedModems() {
+		if modem.VID == vid && modem.PID == pid {
+			return modem, true
+		}
+	}
+
+	return SupportedModem{}, false
+}
+
+func TestLoadModems(t *testing.T) {
+	restoreModems(t)
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"modems.yaml": `
+- vid: 1E0E
+  pid: 9001
+  vendor: SIMCom
+  product: SIM7600
+  at_interface: if03
+  roles: {if01: nmea, if04: modem}
+`,
+		"modems.json": `[{"vid": "05c6", "pid": "90db", "vendor": "SIMCom", "product": "SIM8262", "roles": {"if02": "at", "if00": "diag"}}]`,
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		require.NoError(t, LoadModems(path), name)
+	}
+
+	modem, ok := findSupportedModem("1e0e", "9001")
+	require.True(t, ok)
+	assert.Equal(t, SupportedModem{
+		VID:         "1e0e",
+		PID:         "9001",
+		Vendor:      "SIMCom",
+		Product:     "SIM7600",
+		ATInterface: "if03",
+		Roles:       map[string]PortRole{"if01": RoleNMEA, "if04": RoleModem},
+	}, modem)
+
+	// the AT interface is taken from the roles
+	modem, ok = findSupportedModem("05c6", "90db")
+	require.True(t, ok)
+	assert.Equal(t, "if02", modem.ATInterface)
+	assert.Equal(t, RoleDIAG, modem.role("if00"))
+}
+
+func TestLoadModemsErrors(t *testing.T) {
+	restoreModems(t)
+	dir := t.TempDir()
+
+	tests := map[string]string{
+		"empty":            "[]",
+		"invalid":          "- vid: [",
+		"missing pid":      `[{"vid": "1e0e", "at_interface": "if03"}]`,
+		"no at port":       `[{"vid": "1e0e", "pid": "9001", "roles": {"if01": "nmea"}}]`,
+		"several at ports": `[{"vid": "1e0e", "pid": "9001", "roles": {"if02": "at", "if03": "at"}}]`,
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, "modems.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+			assert.Error(t, LoadModems(path))
+		})
+	}
+
+	assert.Error(t, LoadModems(filepath.Join(dir, "missing.yaml")))
+
+	_, ok := findSupportedModem("1e0e", "9001")
+	assert.False(t, ok)
+}
+
+func TestRegisterModemOverridesBuiltIn(t *testing.T) {
+	restoreModems(t)
+	count := len(SupportedModems())
+
+	require.NoError(t, RegisterModem(SupportedModem{VID: "2C7C", PID: "0125", Vendor: "Quectel", Product: "EG25-G", ATInterface: "if03"}))
+
+	modem, ok := findSupportedModem("2c7c", "0125")
+	require.True(t, ok)
+	assert.Equal(t, "EG25-G", modem.Product)
+	assert.Equal(t, "if03", modem.ATInterface)
+	assert.Len(t, SupportedModems(), count)
+}
+
+func TestSupportedModemsCopiesRoles(t *testing.T) {
+	restoreModems(t)
+
+	roles := map[string]PortRole{"if01": RoleNMEA}
+	require.NoError(t, RegisterModem(SupportedModem{VID: "1e0e", PID: "9001", ATInterface: "if03", Roles: roles}))
+
+	// neither the registered map nor the returned one change the table
+	roles["if01"] = RoleDIAG
+	modem, _ := findSupportedModem("1e0e", "9001")
+	modem.Roles["if04"] = RoleModem
+
+	modem, _ = findSupportedModem("1e0e", "9001")
+	assert.Equal(t, map[string]PortRole{"if01": RoleNMEA}, modem.Roles)
+
+	builtIn, _ := findSupportedModem("2c7c", "0125")
+	builtIn.Roles["if01"] = RoleUnknown
+	assert.Equal(t, RoleNMEA, quectelRoles["if01"])
+}
@@ -0,0 +1,396 @@
+package atcom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// NMEASentence is a parsed NMEA sentence: *NMEAGGA, *NMEARMC, *NMEAGSA,
+// *NMEAGSV or *NMEAVTG
+type NMEASentence interface {
+	// SentenceType returns the type of the sentence, e.g. GGA
+	SentenceType() string
+}
+
+// NMEAGGA is a GGA sentence, the fix data
+type NMEAGGA struct {
+	Talker     string    // GP for GPS, GL for GLONASS, GN for combined fixes...
+	Time       time.Time // time of day in UTC, the date is not included
+	Latitude   float64
+	Longitude  float64
+	Quality    int // 0 without fix
+	Satellites int
+	HDOP       float64
+	Altitude   float64 // meters above sea level
+}
+
+// NMEARMC is an RMC sentence, the recommended minimum data
+type NMEARMC struct {
+	Talker    string
+	Time      time.Time
+	Valid     bool
+	Latitude  float64
+	Longitude float64
+	Speed     float64 // km/h
+	Course    float64 // degrees from true north
+}
+
+// NMEAGSA is a GSA sentence, the active satellites and dilution of precision
+type NMEAGSA struct {
+	Talker string
+	Mode   int   // 1 without fix, 2 for 2D and 3 for 3D fixes
+	PRNs   []int // satellites used for the fix
+	PDOP   float64
+	HDOP   float64
+	VDOP   float64
+}
+
+// NMEAGSV is a GSV sentence, one part of the satellites in view
+type NMEAGSV struct {
+	Talker     string
+	Messages   int // number of GSV sentences of the cycle
+	Number     int // number of this sentence
+	InView     int
+	Satellites []NMEASatellite
+}
+
+// NMEASatellite is a satellite in view
+type NMEASatellite struct {
+	PRN       int
+	Elevation int // degrees
+	Azimuth   int // degrees from true north
+	SNR       int // dB-Hz, 0 when not tracked
+}
+
+// NMEAVTG is a VTG sentence, the course and speed over ground
+type NMEAVTG struct {
+	Talker string
+	Course float64 // degrees from true north
+	Speed  float64 // km/h
+}
+
+func (s *NMEAGGA) SentenceType() string { return "GGA" }
+func (s *NMEARMC) SentenceType() string { return "RMC" }
+func (s *NMEAGSA) SentenceType() string { return "GSA" }
+func (s *NMEAGSV) SentenceType() string { return "GSV" }
+func (s *NMEAVTG) SentenceType() string { return "VTG" }
+
+// knotsToKmh converts a speed in knots to km/h
+const knotsToKmh = 1.852
+
+// ParseNMEA parses a single sentence like $GPGGA,...*47. The checksum is
+// required. Unsupported sentence types return an error.
+func ParseNMEA(line string) (NMEASentence, error) {
+	line = strings.TrimSpace(line)
+
+	if !strings.HasPrefix(line, "$") {
+		return nil, fmt.Errorf("invalid sentence: %s", line)
+	}
+
+	body, checksum, found := strings.Cut(line[1:], "*")
+
+	if !found {
+		return nil, fmt.Errorf("missing checksum: %s", line)
+	}
+
+	expected, err := strconv.ParseUint(checksum, 16, 8)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksum: %s", line)
+	}
+
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+
+	if sum != byte(expected) {
+		return nil, fmt.Errorf("checksum mismatch: %s", line)
+	}
+
+	fields := strings.Split(body, ",")
+
+	if len(fields[0]) != 5 {
+		return nil, fmt.Errorf("invalid sentence: %s", line)
+	}
+
+	talker, kind := fields[0][:2], fields[0][2:]
+	fields = fields[1:]
+
+	// field returns fields[i] or an empty string for missing fields
+	field := func(i int) string {
+		if i < len(fields) {
+			return fields[i]
+		}
+		return ""
+	}
+	float := func(i int) float64 {
+		value, _ := strconv.ParseFloat(field(i), 64)
+		return value
+	}
+	integer := func(i int) int {
+		value, _ := strconv.Atoi(field(i))
+		return value
+	}
+	coordinate := func(i int) float64 {
+		if field(i) == "" {
+			return 0
+		}
+		value, _ := parseNMEACoordinate(field(i), field(i+1))
+		return value
+	}
+	clock := func(i int, date string) time.Time {
+		value, _ := parseNMEATime(date, field(i))
+		return value
+	}
+
+	switch kind {
+	case "GGA":
+		return &NMEAGGA{
+			Talker:     talker,
+			Time:       clock(0, ""),
+			Latitude:   coordinate(1),
+			Longitude:  coordinate(3),
+			Quality:    integer(5),
+			Satellites: integer(6),
+			HDOP:       float(7),
+			Altitude:   float(8),
+		}, nil
+	case "RMC":
+		return &NMEARMC{
+			Talker:    talker,
+			Time:      clock(0, field(8)),
+			Valid:     field(1) == "A",
+			Latitude:  coordinate(2),
+			Longitude: coordinate(4),
+			Speed:     float(6) * knotsToKmh,
+			Course:    float(7),
+		}, nil
+	case "GSA":
+		gsa := &NMEAGSA{Talker: talker, Mode: integer(1), PDOP: float(14), HDOP: float(15), VDOP: float(16)}
+
+		for i := 2; i < 14; i++ {
+			if prn := integer(i); prn != 0 {
+				gsa.PRNs = append(gsa.PRNs, prn)
+			}
+		}
+		return gsa, nil
+	case "GSV":
+		gsv := &NMEAGSV{Talker: talker, Messages: integer(0), Number: integer(1), InView: integer(2)}
+
+		// NMEA 4.10 appends a signal id after the satellites
+		for i := 3; i+3 < len(fields); i += 4 {
+			gsv.Satellites = append(gsv.Satellites, NMEASatellite{
+				PRN:       integer(i),
+				Elevation: integer(i + 1),
+				Azimuth:   integer(i + 2),
+				SNR:       integer(i + 3),
+			})
+		}
+		return gsv, nil
+	case "VTG":
+		return &NMEAVTG{Talker: talker, Course: float(0), Speed: float(6)}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported sentence %s", kind)
+}
+
+// NMEAUpdate is sent by NMEAReader for every valid sentence
+type NMEAUpdate struct {
+	Sentence NMEASentence
+
+	// Fix is the position combined from the sentences received so far,
+	// Mode is 0 while there is no fix
+	Fix Fix
+}
+
+// NMEAReader reads the NMEA port of a GNSS receiver
+type NMEAReader struct {
+	at      *Atcom
+	port    *serial.Port
+	updates chan NMEAUpdate
+	fix     Fix
+	date    time.Time // date of the last RMC sentence
+
+	mu   sync.Mutex
+	err  error
+	done chan struct{}
+}
+
+// DecideNMEAPort returns the NMEA port of the detected modem
+func (t *Atcom) DecideNMEAPort() (string, error) {
+	modem, err := t.DecidePort()
+
+	if err != nil && err != ErrATPortMissing {
+		return "", err
+	}
+
+	return modem.NMEAPort()
+}
+
+// NMEAPort returns the NMEA port of the modem
+func (m *DetectedModem) NMEAPort() (string, error) {
+	if port, ok := m.Ports[RoleNMEA]; ok {
+		return port, nil
+	}
+
+	return "", fmt.Errorf("no nmea port known for %s %s", m.Vendor, m.Product)
+}
+
+// OpenNMEA starts reading the NMEA port on attr. Updates are sent until
+// Close is called or reading fails.
+func (t *Atcom) OpenNMEA(attr SerialAttr) (*NMEAReader, error) {
+	port, err := t.open(attr.Port, attr.Baud)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r := &NMEAReader{
+		at:      t,
+		port:    port,
+		updates: make(chan NMEAUpdate, 16),
+		done:    make(chan struct{}),
+	}
+
+	go r.readLoop()
+
+	return r, nil
+}
+
+// Updates returns the channel of the updates, closed when the reader stops
+func (r *NMEAReader) Updates() <-chan NMEAUpdate {
+	return r.updates
+}
+
+// Err returns the error that stopped the reader
+func (r *NMEAReader) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+// Close stops the reader and closes the port
+func (r *NMEAReader) Close() error {
+	return r.stop(nil)
+}
+
+func (r *NMEAReader) stop(err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return errors.New("nmea reader is closed")
+	default:
+	}
+
+	r.err = err
+	close(r.done)
+	return r.at.serial.Close(r.port)
+}
+
+func (r *NMEAReader) readLoop() {
+	defer close(r.updates)
+
+	buf := make([]byte, 1024)
+	data := make([]byte, 0)
+
+	for {
+		select {
+		case <-r.done:
+			return
+		default:
+		}
+
+		n, err := r.at.serial.Read(r.port, buf)
+
+		if err != nil {
+			if err.Error() == "EOF" {
+				time.Sleep(time.Millisecond * 5)
+				continue
+			}
+
+			r.stop(err)
+			return
+		}
+
+		data = append(data, buf[:n]...)
+
+		for {
+			end := bytes.IndexByte(data, '\n')
+
+			if end < 0 {
+				break
+			}
+
+			line := string(data[:end])
+			data = data[end+1:]
+
+			sentence, err := ParseNMEA(line)
+
+			if err != nil {
+				continue
+			}
+
+			select {
+			case r.updates <- NMEAUpdate{Sentence: sentence, Fix: r.update(sentence)}:
+			case <-r.done:
+				return
+			}
+		}
+	}
+}
+
+// update merges sentence into the current fix
+func (r *NMEAReader) update(sentence NMEASentence) Fix {
+	switch s := sentence.(type) {
+	case *NMEAGGA:
+		if s.Quality == 0 {
+			r.fix.Mode = 0
+			break
+		}
+
+		r.fix.Latitude, r.fix.Longitude = s.Latitude, s.Longitude
+		r.fix.Altitude, r.fix.HDOP = s.Altitude, s.HDOP
+		r.fix.Satellites = s.Satellites
+		r.fix.Mode = max(r.fix.Mode, 2)
+
+		if !r.date.IsZero() {
+			r.fix.Time = r.date.Add(s.Time.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)))
+		}
+	case *NMEARMC:
+		if !s.Time.IsZero() {
+			r.date = s.Time.Truncate(24 * time.Hour)
+		}
+
+		if !s.Valid {
+			r.fix.Mode = 0
+			break
+		}
+
+		r.fix.Latitude, r.fix.Longitude = s.Latitude, s.Longitude
+		r.fix.Speed, r.fix.Course = s.Speed, s.Course
+		r.fix.Time = s.Time
+		r.fix.Mode = max(r.fix.Mode, 2)
+	case *NMEAGSA:
+		// every constellation reports a GSA, the best mode wins
+		if s.Mode >= 2 {
+			r.fix.Mode = max(r.fix.Mode, s.Mode)
+		}
+	case *NMEAVTG:
+		if r.fix.Mode >= 2 {
+			r.fix.Speed, r.fix.Course = s.Speed, s.Course
+		}
+	}
+
+	return r.fix
+}
@@ -0,0 +1,133 @@
+package atcom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNMEA(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		sentence NMEASentence
+	}{
+		{
+			name: "GGA",
+			line: "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47\r\n",
+			sentence: &NMEAGGA{
+				Talker:     "GP",
+				Time:       time.Date(0, 1, 1, 12, 35, 19, 0, time.UTC),
+				Latitude:   48.1173,
+				Longitude:  11.516666666666667,
+				Quality:    1,
+				Satellites: 8,
+				HDOP:       0.9,
+				Altitude:   545.4,
+			},
+		},
+		{
+			name: "RMC",
+			line: "$GPRMC,123519,A,4807.038,S,01131.000,W,022.4,084.4,230394,003.1,W*65",
+			sentence: &NMEARMC{
+				Talker:    "GP",
+				Time:      time.Date(1994, 3, 23, 12, 35, 19, 0, time.UTC),
+				Valid:     true,
+				Latitude:  -48.1173,
+				Longitude: -11.516666666666667,
+				Speed:     22.4 * knotsToKmh,
+				Course:    84.4,
+			},
+		},
+		{
+			name: "GSA",
+			line: "$GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.1*39",
+			sentence: &NMEAGSA{
+				Talker: "GP",
+				Mode:   3,
+				PRNs:   []int{4, 5, 9, 12, 24},
+				PDOP:   2.5,
+				HDOP:   1.3,
+				VDOP:   2.1,
+			},
+		},
+		{
+			name: "GSV",
+			line: "$GPGSV,2,1,08,01,40,083,46,02,17,308,41,12,07,344,39,14,22,228,45*75",
+			sentence: &NMEAGSV{
+				Talker:   "GP",
+				Messages: 2,
+				Number:   1,
+				InView:   8,
+				Satellites: []NMEASatellite{
+					{PRN: 1, Elevation: 40, Azimuth: 83, SNR: 46},
+					{PRN: 2, Elevation: 17, Azimuth: 308, SNR: 41},
+					{PRN: 12, Elevation: 7, Azimuth: 344, SNR: 39},
+					{PRN: 14, Elevation: 22, Azimuth: 228, SNR: 45},
+				},
+			},
+		},
+		{
+			name:     "VTG",
+			line:     "$GPVTG,054.7,T,034.4,M,005.5,N,010.2,K*48",
+			sentence: &NMEAVTG{Talker: "GP", Course: 54.7, Speed: 10.2},
+		},
+		{
+			name:     "GGA without fix",
+			line:     "$GNGGA,,,,,,0,00,99.99,,,,,,*56",
+			sentence: &NMEAGGA{Talker: "GN", HDOP: 99.99},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sentence, err := ParseNMEA(tt.line)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.sentence.SentenceType(), sentence.SentenceType())
+			assert.InDeltaMapValues(t, coordinates(tt.sentence), coordinates(sentence), 1e-9)
+			assert.Equal(t, tt.sentence, withoutCoordinates(sentence, tt.sentence))
+		})
+	}
+}
+
+func TestParseNMEAErrors(t *testing.T) {
+	for name, line := range map[string]string{
+		"checksum mismatch": "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*48",
+		"missing checksum":  "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,",
+		"invalid checksum":  "$GPGGA,123519*ZZ",
+		"missing dollar":    "GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47",
+		"unsupported":       "$GPZDA,201530.00,04,07,2002,00,00*60",
+		"invalid address":   "$GPGGAX,1*13",
+	} {
+		_, err := ParseNMEA(line)
+		assert.Error(t, err, name)
+	}
+}
+
+// coordinates returns the floating point coordinates of a sentence
+func coordinates(sentence NMEASentence) map[string]float64 {
+	switch s := sentence.(type) {
+	case *NMEAGGA:
+		return map[string]float64{"lat": s.Latitude, "lon": s.Longitude}
+	case *NMEARMC:
+		return map[string]float64{"lat": s.Latitude, "lon": s.Longitude, "speed": s.Speed}
+	}
+	return map[string]float64{}
+}
+
+// withoutCoordinates copies the coordinates of expected into sentence, they
+// are compared with a tolerance
+func withoutCoordinates(sentence NMEASentence, expected NMEASentence) NMEASentence {
+	switch s := sentence.(type) {
+	case *NMEAGGA:
+		e := expected.(*NMEAGGA)
+		s.Latitude, s.Longitude = e.Latitude, e.Longitude
+	case *NMEARMC:
+		e := expected.(*NMEARMC)
+		s.Latitude, s.Longitude, s.Speed = e.Latitude, e.Longitude, e.Speed
+	}
+	return sentence
+}
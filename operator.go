@@ -0,0 +1,196 @@
+package atcom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// OperatorStatus is the availability of an operator reported by AT+COPS=?
+type OperatorStatus int
+
+const (
+	OperatorUnknown OperatorStatus = iota
+	OperatorAvailable
+	OperatorCurrent
+	OperatorForbidden
+)
+
+// AccessTechnology is the <AcT> parameter of network commands
+type AccessTechnology int
+
+const (
+	AcTUnspecified AccessTechnology = -1
+	AcTGSM         AccessTechnology = 0
+	AcTUTRAN       AccessTechnology = 2
+	AcTGSMEGPRS    AccessTechnology = 3
+	AcTEUTRAN      AccessTechnology = 7
+	AcTECGSMIoT    AccessTechnology = 8
+	AcTEUTRANNB    AccessTechnology = 9
+	AcTNR5GCN      AccessTechnology = 11
+	AcTNGRAN       AccessTechnology = 12
+	AcTEUTRANNR    AccessTechnology = 13
+)
+
+// OperatorMode is the <mode> of AT+COPS
+type OperatorMode int
+
+const (
+	SelectionAutomatic OperatorMode = iota
+	SelectionManual
+	SelectionDeregister
+	SelectionFormatOnly
+	SelectionManualAutomatic
+)
+
+// OperatorFormat is the <format> of the operator name in AT+COPS
+type OperatorFormat int
+
+const (
+	FormatLong OperatorFormat = iota
+	FormatShort
+	FormatNumeric
+)
+
+// Operator is an entry of the AT+COPS=? operator list
+type Operator struct {
+	Status  OperatorStatus
+	Long    string
+	Short   string
+	Numeric string // MCC and MNC, e.g. 26202
+	MCC     string
+	MNC     string
+	AcT     AccessTechnology
+}
+
+// operatorScanTimeout is used when ScanOperators is called without deadline
+const operatorScanTimeout = 180 * time.Second
+
+// ScanOperators searches the available networks with AT+COPS=?, which can
+// take several minutes. Without a deadline on ctx it gives up after 180 seconds.
+func (t *Atcom) ScanOperators(ctx context.Context, attr SerialAttr) ([]Operator, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, operatorScanTimeout)
+		defer cancel()
+	}
+
+	com := NewATCommand("AT+COPS=?")
+	com.SerialAttr = attr
+	com = t.sendATContext(ctx, com)
+
+	if com.Error != nil {
+		return nil, com.Error
+	}
+
+	operators := make([]Operator, 0)
+
+	for _, line := range linesWithPrefix(com.Response, "+COPS:") {
+		result, err := ParseTestResult("+COPS: " + line)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// the operator list is followed by the supported modes and formats,
+		// usually but not always separated by an empty parameter
+		for _, param := range result.Params {
+			if !isOperator(param) {
+				return operators, nil
+			}
+
+			operator, err := parseOperator(param.Values)
+
+			if err != nil {
+				return nil, err
+			}
+
+			operators = append(operators, operator)
+		}
+	}
+
+	return operators, nil
+}
+
+// isOperator reports whether a group of AT+COPS=? is an operator entry,
+// which starts with a <stat> digit followed by the quoted operator names
+func isOperator(param TestParam) bool {
+	if len(param.Values) < 4 {
+		return false
+	}
+
+	stat := param.Values[0]
+
+	if stat.Quoted || stat.Range || len(stat.Value) != 1 || stat.Value[0] < '0' || stat.Value[0] > '9' {
+		return false
+	}
+
+	return param.Values[1].Quoted
+}
+
+func parseOperator(values []TestValue) (Operator, error) {
+	if len(values) < 4 {
+		return Operator{}, fmt.Errorf("invalid operator entry: %v", values)
+	}
+
+	status, err := strconv.Atoi(values[0].Value)
+
+	if err != nil {
+		return Operator{}, fmt.Errorf("invalid operator status: %s", values[0].Value)
+	}
+
+	operator := Operator{
+		Status:  OperatorStatus(status),
+		Long:    values[1].Value,
+		Short:   values[2].Value,
+		Numeric: values[3].Value,
+		AcT:     AcTUnspecified,
+	}
+
+	if len(operator.Numeric) >= 5 {
+		operator.MCC = operator.Numeric[:3]
+		operator.MNC = operator.Numeric[3:]
+	}
+
+	if len(values) > 4 {
+		if act, err := strconv.Atoi(values[4].Value); err == nil {
+			operator.AcT = AccessTechnology(act)
+		}
+	}
+
+	return operator, nil
+}
+
+// SelectOperator registers to an operator with AT+COPS. oper is given in
+// format, act is omitted when AcTUnspecified. Manual selection requires oper.
+func (t *Atcom) SelectOperator(attr SerialAttr, mode OperatorMode, format OperatorFormat, oper string, act AccessTechnology) error {
+	if oper == "" && (mode == SelectionManual || mode == SelectionManualAutomatic) {
+		return errors.New("manual operator selection requires an operator")
+	}
+
+	command := fmt.Sprintf("AT+COPS=%d", mode)
+
+	switch {
+	case mode == SelectionFormatOnly:
+		command += fmt.Sprintf(",%d", format)
+	case oper != "":
+		command += fmt.Sprintf(",%d,\"%s\"", format, oper)
+
+		if act != AcTUnspecified {
+			command += fmt.Sprintf(",%d", act)
+		}
+	}
+
+	com := NewATCommand(command)
+	com.SerialAttr = attr
+	com.Timeout = int(operatorScanTimeout / time.Second)
+	com = t.SendAT(com)
+	return com.Error
+}
+
+// AutomaticSelection lets the modem choose the operator
+func (t *Atcom) AutomaticSelection(attr SerialAttr) error {
+	return t.SelectOperator(attr, SelectionAutomatic, FormatLong, "", AcTUnspecified)
+}
@@ -0,0 +1,111 @@
+package atcom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOperator(t *testing.T) {
+	result, err := ParseTestResult(`+COPS: (2,"Telekom.de","TDG","26201",7),,(0-4),(0-2)`)
+	require.NoError(t, err)
+
+	operator, err := parseOperator(result.Params[0].Values)
+	require.NoError(t, err)
+
+	assert.Equal(t, Operator{
+		Status:  OperatorStatus(2),
+		Long:    "Telekom.de",
+		Short:   "TDG",
+		Numeric: "26201",
+		MCC:     "262",
+		MNC:     "01",
+		AcT:     AccessTechnology(7),
+	}, operator)
+
+	_, err = parseOperator(result.Params[1].Values)
+	assert.Error(t, err)
+}
+
+func TestScanOperators(t *testing.T) {
+	telekom := Operator{
+		Status:  OperatorCurrent,
+		Long:    "Telekom.de",
+		Short:   "TDG",
+		Numeric: "26201",
+		MCC:     "262",
+		MNC:     "01",
+		AcT:     AcTEUTRAN,
+	}
+	vodafone := Operator{
+		Status:  OperatorAvailable,
+		Long:    "Vodafone.de",
+		Short:   "VF",
+		Numeric: "26202",
+		MCC:     "262",
+		MNC:     "02",
+		AcT:     AcTGSM,
+	}
+
+	tests := []struct {
+		name      string
+		response  string
+		operators []Operator
+	}{
+		{
+			name:      "with separator",
+			response:  `+COPS: (2,"Telekom.de","TDG","26201",7),(1,"Vodafone.de","VF","26202",0),,(0-4),(0-2)`,
+			operators: []Operator{telekom, vodafone},
+		},
+		{
+			name:      "without separator",
+			response:  `+COPS: (2,"Telekom.de","TDG","26201",7),(1,"Vodafone.de","VF","26202",0),(0-4),(0-2)`,
+			operators: []Operator{telekom, vodafone},
+		},
+		{
+			name:      "modes as list",
+			response:  `+COPS: (2,"Telekom.de","TDG","26201",7),(0,1,2,3,4),(0,1,2)`,
+			operators: []Operator{telekom},
+		},
+		{
+			name:      "no operators",
+			response:  `+COPS: ,,(0-4),(0-2)`,
+			operators: []Operator{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modem := newFakeModem()
+			modem.on("AT+COPS=?", "\r\n"+tt.response+"\r\n\r\nOK\r\n")
+
+			at := NewAtcom(modem, nil)
+			operators, err := at.ScanOperators(context.Background(), SerialAttr{Port: "/dev/ttyUSB2"})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.operators, operators)
+		})
+	}
+}
+
+func TestSelectOperator(t *testing.T) {
+	modem := newFakeModem()
+	at := NewAtcom(modem, nil)
+	attr := SerialAttr{Port: "/dev/ttyUSB2"}
+
+	require.NoError(t, at.SelectOperator(attr, SelectionManual, FormatNumeric, "26201", AcTEUTRAN))
+	require.NoError(t, at.SelectOperator(attr, SelectionFormatOnly, FormatNumeric, "", AcTUnspecified))
+	require.NoError(t, at.AutomaticSelection(attr))
+
+	assert.Equal(t, []string{
+		`AT+COPS=1,2,"26201",7`,
+		"AT+COPS=3,2",
+		"AT+COPS=0",
+	}, modem.commands())
+
+	assert.Error(t, at.SelectOperator(attr, SelectionManual, FormatLong, "", AcTUnspecified))
+	assert.Error(t, at.SelectOperator(attr, SelectionManualAutomatic, FormatLong, "", AcTEUTRAN))
+	assert.Len(t, modem.commands(), 3)
+}
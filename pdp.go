@@ -0,0 +1,239 @@
+package atcom
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// PDPAuth is the authentication protocol of a PDP context
+type PDPAuth int
+
+const (
+	AuthNone PDPAuth = iota
+	AuthPAP
+	AuthCHAP
+	AuthPAPOrCHAP
+)
+
+// PDPContext is a defined PDP context as reported by AT+CGDCONT? and AT+CGACT?
+type PDPContext struct {
+	CID    int
+	Type   string // IP, IPV6 or IPV4V6
+	APN    string
+	Active bool
+}
+
+// PDPConfig describes a PDP context to define
+type PDPConfig struct {
+	CID      int
+	Type     string // IP when empty
+	APN      string
+	Auth     PDPAuth
+	Username string
+	Password string
+}
+
+// PDPContexts lists the defined PDP contexts and their activation state
+func (t *Atcom) PDPContexts(attr SerialAttr) ([]PDPContext, error) {
+	com := NewATCommand("AT+CGDCONT?")
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+
+	if com.Error != nil {
+		return nil, com.Error
+	}
+
+	contexts := make([]PDPContext, 0)
+
+	for _, line := range linesWithPrefix(com.Response, "+CGDCONT:") {
+		params := splitParams(line)
+		cid, err := strconv.Atoi(params[0])
+
+		if err != nil || len(params) < 3 {
+			return nil, fmt.Errorf("invalid response: %s", line)
+		}
+
+		contexts = append(contexts, PDPContext{CID: cid, Type: params[1], APN: params[2]})
+	}
+
+	com = NewATCommand("AT+CGACT?")
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+
+	if com.Error != nil {
+		return nil, com.Error
+	}
+
+	for _, line := range linesWithPrefix(com.Response, "+CGACT:") {
+		params := splitParams(line)
+
+		if len(params) < 2 {
+			continue
+		}
+
+		for i := range contexts {
+			if strconv.Itoa(contexts[i].CID) == params[0] {
+				contexts[i].Active = params[1] == "1"
+			}
+		}
+	}
+
+	return contexts, nil
+}
+
+// DefinePDPContext defines a PDP context with AT+CGDCONT and configures its
+// authentication with the command of the modem vendor
+func (t *Atcom) DefinePDPContext(attr SerialAttr, cfg PDPConfig) error {
+	if cfg.Type == "" {
+		cfg.Type = "IP"
+	}
+
+	var auth string
+
+	if cfg.Auth != AuthNone || cfg.Username != "" || cfg.Password != "" {
+		command, err := t.pdpAuthCommand(attr, cfg)
+
+		if err != nil {
+			return err
+		}
+
+		auth = command
+	}
+
+	com := NewATCommand(fmt.Sprintf("AT+CGDCONT=%d,\"%s\",\"%s\"", cfg.CID, cfg.Type, cfg.APN))
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+
+	if com.Error != nil || auth == "" {
+		return com.Error
+	}
+
+	com = NewATCommand(auth)
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+	return com.Error
+}
+
+// pdpAuthCommand returns the vendor command configuring the authentication
+// of cfg. AuthPAPOrCHAP is an error where the vendor has no such value.
+func (t *Atcom) pdpAuthCommand(attr SerialAttr, cfg PDPConfig) (string, error) {
+	if cfg.Auth < AuthNone || cfg.Auth > AuthPAPOrCHAP {
+		return "", fmt.Errorf("invalid authentication: %d", cfg.Auth)
+	}
+
+	vendor, err := t.vendor(attr)
+
+	if err != nil {
+		return "", err
+	}
+
+	switch vendor {
+	case "Quectel":
+		contextType := map[string]int{"IP": 1, "IPV6": 2, "IPV4V6": 3}[strings.ToUpper(cfg.Type)]
+		return fmt.Sprintf("AT+QICSGP=%d,%d,\"%s\",\"%s\",\"%s\",%d",
+			cfg.CID, max(contextType, 1), cfg.APN, cfg.Username, cfg.Password, cfg.Auth), nil
+	case "Thales/Cinterion":
+		// Cinterion expects the password before the user name
+		return fmt.Sprintf("AT^SGAUTH=%d,%d,\"%s\",\"%s\"", cfg.CID, cfg.Auth, cfg.Password, cfg.Username), nil
+	}
+
+	// #PDPAUTH and +CGAUTH only know a single protocol
+	if cfg.Auth == AuthPAPOrCHAP {
+		return "", errors.New("the modem does not support PAP or CHAP authentication, choose one")
+	}
+
+	if vendor == "Telit" {
+		return fmt.Sprintf("AT#PDPAUTH=%d,%d,\"%s\",\"%s\"", cfg.CID, cfg.Auth, cfg.Username, cfg.Password), nil
+	}
+
+	return fmt.Sprintf("AT+CGAUTH=%d,%d,\"%s\",\"%s\"", cfg.CID, cfg.Auth, cfg.Username, cfg.Password), nil
+}
+
+// DeletePDPContext removes the definition of a PDP context
+func (t *Atcom) DeletePDPContext(attr SerialAttr, cid int) error {
+	com := NewATCommand(fmt.Sprintf("AT+CGDCONT=%d", cid))
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+	return com.Error
+}
+
+// ActivatePDPContext activates a PDP context, which may take up to 150 seconds
+func (t *Atcom) ActivatePDPContext(attr SerialAttr, cid int) error {
+	com := NewATCommand(fmt.Sprintf("AT+CGACT=1,%d", cid))
+	com.SerialAttr = attr
+	com.Timeout = 150
+	com = t.SendAT(com)
+	return com.Error
+}
+
+// DeactivatePDPContext deactivates a PDP context
+func (t *Atcom) DeactivatePDPContext(attr SerialAttr, cid int) error {
+	com := NewATCommand(fmt.Sprintf("AT+CGACT=0,%d", cid))
+	com.SerialAttr = attr
+	com.Timeout = 40
+	com = t.SendAT(com)
+	return com.Error
+}
+
+// PDPAddresses returns the IPv4 and IPv6 addresses assigned to a PDP
+// context, empty when not assigned
+func (t *Atcom) PDPAddresses(attr SerialAttr, cid int) (ipv4 string, ipv6 string, err error) {
+	com := NewATCommand(fmt.Sprintf("AT+CGPADDR=%d", cid))
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+
+	if com.Error != nil {
+		return "", "", com.Error
+	}
+
+	for _, line := range linesWithPrefix(com.Response, "+CGPADDR:") {
+		params := splitParams(line)
+
+		if params[0] != strconv.Itoa(cid) {
+			continue
+		}
+
+		for _, param := range params[1:] {
+			// some modems report both addresses in one parameter
+			for _, address := range strings.Fields(param) {
+				ip := parsePDPAddress(address)
+
+				switch {
+				case ip == nil || ip.IsUnspecified():
+				case ip.To4() != nil:
+					ipv4 = ip.String()
+				default:
+					ipv6 = ip.String()
+				}
+			}
+		}
+	}
+
+	return ipv4, ipv6, nil
+}
+
+// parsePDPAddress parses an address in the notation of 3GPP TS 27.007, where
+// IPv6 addresses may be written as 16 dot separated decimal octets
+func parsePDPAddress(address string) net.IP {
+	octets := strings.Split(address, ".")
+
+	if len(octets) != 16 {
+		return net.ParseIP(address)
+	}
+
+	ip := make(net.IP, 16)
+
+	for i, octet := range octets {
+		value, err := strconv.Atoi(octet)
+
+		if err != nil || value > 255 {
+			return nil
+		}
+		ip[i] = byte(value)
+	}
+
+	return ip
+}
@@ -0,0 +1,49 @@
+package atcom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefinePDPContextAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		vendor  string
+		auth    PDPAuth
+		command string // empty when the configuration is rejected
+	}{
+		{"quectel", "Quectel", AuthPAPOrCHAP, `AT+QICSGP=1,1,"internet","user","secret",3`},
+		{"cinterion", "Cinterion", AuthPAPOrCHAP, `AT^SGAUTH=1,3,"secret","user"`},
+		{"telit", "Telit", AuthCHAP, `AT#PDPAUTH=1,2,"user","secret"`},
+		{"telit pap or chap", "Telit", AuthPAPOrCHAP, ""},
+		{"standard", "SIMCOM INCORPORATED", AuthPAP, `AT+CGAUTH=1,1,"user","secret"`},
+		{"standard pap or chap", "SIMCOM INCORPORATED", AuthPAPOrCHAP, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modem := newFakeModem()
+			modem.on("AT+CGMI", "\r\n"+tt.vendor+"\r\n\r\nOK\r\n")
+
+			at := NewAtcom(modem, nil)
+			err := at.DefinePDPContext(SerialAttr{Port: "/dev/ttyUSB2"}, PDPConfig{
+				CID:      1,
+				APN:      "internet",
+				Auth:     tt.auth,
+				Username: "user",
+				Password: "secret",
+			})
+
+			if tt.command == "" {
+				assert.Error(t, err)
+				assert.NotContains(t, modem.commands(), `AT+CGDCONT=1,"IP","internet"`)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, []string{"AT+CGMI", `AT+CGDCONT=1,"IP","internet"`, tt.command}, modem.commands())
+		})
+	}
+}
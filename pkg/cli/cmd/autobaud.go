@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	atcom "github.com/sixfab/atcomv2"
+	"github.com/spf13/cobra"
+)
+
+// autobaudCmd represents the autobaud command
+// It finds the baud rate of a modem attached to a UART
+var autobaudCmd = &cobra.Command{
+	Use:   "autobaud",
+	Short: "Find the baud rate of a UART modem",
+	Long:  `Send AT at every baud rate until the modem answers and print the working rate`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		port := cmd.Flag("port").Value.String()
+		lock, _ := strconv.ParseBool(cmd.Flag("lock").Value.String())
+		rates, _ := cmd.Flags().GetIntSlice("rates")
+
+		at := atcom.NewAtcom(nil, nil)
+
+		attr, err := at.Autobaud(port, atcom.AutobaudOptions{Rates: rates, Lock: lock})
+
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println(attr.Baud)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(autobaudCmd)
+
+	autobaudCmd.Flags().StringP("port", "p", "/dev/ttyS0", "port name")
+	autobaudCmd.Flags().IntSliceP("rates", "r", nil, "baud rates to try, separated with commas")
+	autobaudCmd.Flags().BoolP("lock", "l", false, "store the found rate in the modem with AT+IPR")
+}
@@ -1,10 +1,12 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	atcom "github.com/sixfab/atcomv2"
 	"github.com/spf13/cobra"
@@ -33,48 +35,134 @@ var detectCmd = &cobra.Command{
 		portFlag := cmd.Flag("port").Value.String()
 		vendorFlag := cmd.Flag("vendor").Value.String()
 		modelFlag := cmd.Flag("model").Value.String()
+		waitFlag := cmd.Flag("wait").Value.String()
+		listFlag := cmd.Flag("list").Value.String()
+		probeFlag := cmd.Flag("probe").Value.String()
 
 		at := atcom.NewAtcom(nil, nil)
 
-		modem, err := at.DecidePort()
+		if waitFlag == "true" {
+			waitForModem(at, cmd)
+		}
+
+		if listFlag == "true" {
+			modems, err := at.DetectAll()
+
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			for i, modem := range modems {
+				fmt.Printf("%d: %s %s port:%s serial:%s path:%s\n",
+					i, modem.Vendor, modem.Product, modem.Port, modem.Serial, modem.USBPath)
+			}
+			return
+		}
+
+		if probeFlag == "true" {
+			results, err := at.ProbePorts()
+
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			for _, result := range results {
+				switch {
+				case result.Role == atcom.RoleDIAG, result.Role == atcom.RoleQMI, result.Role == atcom.RoleMBIM:
+					fmt.Printf("%s %s:%s %s %s port not probed\n", result.Port, result.VID, result.PID, result.Interface, result.Role)
+				case result.InUse:
+					fmt.Printf("%s %s:%s %s in use\n", result.Port, result.VID, result.PID, result.Interface)
+				case result.Responds:
+					fmt.Printf("%s %s:%s %s responds manufacturer:%s model:%s info:%s\n", result.Port, result.VID,
+						result.PID, result.Interface, result.Manufacturer, result.Model, result.Info)
+				default:
+					fmt.Printf("%s %s:%s %s no response\n", result.Port, result.VID, result.PID, result.Interface)
+				}
+			}
+			return
+		}
+
+		modem, err := detectModem(at, cmd)
 
 		if err != nil {
 			fmt.Println(err)
 		}
 
+		if modem == nil {
+			os.Exit(1)
+		}
+
 		if allFlag == "true" {
-			for key, value := range modem {
-				fmt.Println(key + ":" + value)
+			fmt.Println("port:" + modem.Port)
+			fmt.Println("vid:" + modem.VID)
+			fmt.Println("pid:" + modem.PID)
+			fmt.Println("vendor:" + modem.Vendor)
+			fmt.Println("model:" + modem.Product)
+			fmt.Println("usb_path:" + modem.USBPath)
+			fmt.Println("serial:" + modem.Serial)
+
+			for _, port := range modem.Interfaces {
+				fmt.Printf("%s:%s (%s)\n", port.Interface, port.Port, port.Role)
 			}
 		}
 
 		if vidFlag == "true" {
-			fmt.Println(modem["vid"])
+			fmt.Println(modem.VID)
 		}
 
 		if pidFlag == "true" {
-			fmt.Println(modem["pid"])
+			fmt.Println(modem.PID)
 		}
 
 		if portFlag == "true" {
-			fmt.Println(modem["port"])
+			fmt.Println(modem.Port)
 		}
 
 		if vendorFlag == "true" {
-			fmt.Println(modem["vendor"])
+			fmt.Println(modem.Vendor)
 		}
 
 		if modelFlag == "true" {
-			fmt.Println(modem["model"])
+			fmt.Println(modem.Product)
 		}
 
 		if allFlag == "false" && vidFlag == "false" && pidFlag == "false" &&
 			vendorFlag == "false" && modelFlag == "false" {
-			fmt.Println(modem["port"])
+			fmt.Println(modem.Port)
 		}
 	},
 }
 
+// waitForModem blocks until a supported modem is attached and its port is
+// detected
+func waitForModem(at *atcom.Atcom, cmd *cobra.Command) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := at.Watch(ctx)
+
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	for event := range events {
+		if event.Type != atcom.ModemAttached {
+			continue
+		}
+
+		// the tty ports appear shortly after the USB device
+		for i := 0; i < 30; i++ {
+			if _, err := detectModem(at, cmd); err == nil {
+				return
+			}
+			time.Sleep(time.Second)
+		}
+	}
+}
+
 // urcCmd represents the urc command
 // This command is used to listen for responses without sending any command
 // Until the timeout, desired response, or a standard "OK"/"ERROR" is received
@@ -115,16 +203,7 @@ var urcCmd = &cobra.Command{
 
 		at := atcom.NewAtcom(nil, nil)
 
-		if port == "" {
-			detected, err := at.DecidePort()
-
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
-
-			port = detected["port"]
-		}
+		port = portOrDetect(at, cmd, port)
 
 		responseChan := make(chan string)
 		defer close(responseChan)
@@ -192,16 +271,7 @@ var rootCmd = &cobra.Command{
 
 		at := atcom.NewAtcom(nil, nil)
 
-		if port == "" {
-			detected, err := at.DecidePort()
-
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
-
-			port = detected["port"]
-		}
+		port = portOrDetect(at, cmd, port)
 
 		// If verbose mode is enabled, print parameters and responses until timeout,
 		// desired response, or a standard "OK"/"ERROR" is received
@@ -265,6 +335,40 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+// detectModem detects the modem chosen with the modem flag, the first
+// supported modem without it
+func detectModem(at *atcom.Atcom, cmd *cobra.Command) (*atcom.DetectedModem, error) {
+	selector := cmd.Flag("modem").Value.String()
+
+	if selector == "" {
+		return at.DecidePort()
+	}
+
+	sel, err := atcom.ParseModemSelector(selector)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return at.SelectModem(sel)
+}
+
+// portOrDetect returns port, or the AT port of the detected modem when port is empty
+func portOrDetect(at *atcom.Atcom, cmd *cobra.Command, port string) string {
+	if port != "" {
+		return port
+	}
+
+	detected, err := detectModem(at, cmd)
+
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	return detected.Port
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -291,6 +395,7 @@ func init() {
 	rootCmd.Flags().BoolP("lineend", "l", true, "line end")
 	rootCmd.Flags().BoolP("verbose", "v", false, "verbose mode")
 	rootCmd.Flags().StringP("version", "V", "", "version")
+	rootCmd.PersistentFlags().String("modem", "", "modem to use: index, serial:<serial>, path:<usb path> or imei:<imei>")
 
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(detectCmd)
@@ -309,4 +414,7 @@ func init() {
 	detectCmd.Flags().BoolP("port", "p", false, "serial port")
 	detectCmd.Flags().BoolP("vendor", "e", false, "vendor name")
 	detectCmd.Flags().BoolP("model", "m", false, "model name")
+	detectCmd.Flags().BoolP("wait", "w", false, "wait until a modem is attached")
+	detectCmd.Flags().BoolP("list", "L", false, "list all attached modems")
+	detectCmd.Flags().BoolP("probe", "P", false, "probe all USB serial ports for AT responses")
 }
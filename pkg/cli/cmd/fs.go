@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	atcom "github.com/sixfab/atcomv2"
+	"github.com/spf13/cobra"
+)
+
+// fsCmd represents the fs command
+// Its subcommands manage the file system of Quectel modules
+var fsCmd = &cobra.Command{
+	Use:   "fs",
+	Short: "Manage files on Quectel modules",
+	Long:  `List, upload, download and delete files on the UFS and RAM storage of Quectel modules`,
+}
+
+// fsLsCmd represents the fs ls command
+var fsLsCmd = &cobra.Command{
+	Use:   "ls [pattern]",
+	Short: "List files",
+	Long:  `List files matching the pattern, e.g. "UFS:*" or "RAM:*"`,
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		pattern := "*"
+		if len(args) > 0 {
+			pattern = args[0]
+		}
+
+		files, err := quectelFromFlags(cmd).ListFiles(pattern)
+
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		for _, file := range files {
+			fmt.Printf("%10d %s\n", file.Size, file.Name)
+		}
+	},
+}
+
+// fsPutCmd represents the fs put command
+var fsPutCmd = &cobra.Command{
+	Use:   "put <local> [remote]",
+	Short: "Upload a file",
+	Long:  `Upload a local file, stored on UFS with its base name unless remote is given`,
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		remote := filepath.Base(args[0])
+		if len(args) > 1 {
+			remote = args[1]
+		}
+
+		data, err := os.ReadFile(args[0])
+
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		err = quectelFromFlags(cmd).Upload(remote, data)
+
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+// fsGetCmd represents the fs get command
+var fsGetCmd = &cobra.Command{
+	Use:   "get <remote> [local]",
+	Short: "Download a file",
+	Long:  `Download a file, saved with its base name unless local is given. Use - to print it`,
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		// strip the storage prefix like "UFS:" from the local name
+		local := args[0]
+		if index := strings.LastIndex(local, ":"); index >= 0 {
+			local = local[index+1:]
+		}
+		local = filepath.Base(local)
+
+		if len(args) > 1 {
+			local = args[1]
+		}
+
+		data, err := quectelFromFlags(cmd).Download(args[0])
+
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if local == "-" {
+			os.Stdout.Write(data)
+			return
+		}
+
+		err = os.WriteFile(local, data, 0644)
+
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+// fsRmCmd represents the fs rm command
+var fsRmCmd = &cobra.Command{
+	Use:   "rm <remote>",
+	Short: "Delete a file",
+	Long:  `Delete a file, wildcards like "UFS:*" are allowed`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		err := quectelFromFlags(cmd).DeleteFile(args[0])
+
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+// quectelFromFlags returns the Quectel API for the port and baud flags
+func quectelFromFlags(cmd *cobra.Command) *atcom.Quectel {
+	port := cmd.Flag("port").Value.String()
+	baud, _ := strconv.Atoi(cmd.Flag("baud").Value.String())
+
+	at := atcom.NewAtcom(nil, nil)
+
+	attr := atcom.DefaultSerialAttr()
+	attr.Port = portOrDetect(at, cmd, port)
+	attr.Baud = baud
+
+	return at.Quectel(attr)
+}
+
+func init() {
+	rootCmd.AddCommand(fsCmd)
+
+	fsCmd.AddCommand(fsLsCmd)
+	fsCmd.AddCommand(fsPutCmd)
+	fsCmd.AddCommand(fsGetCmd)
+	fsCmd.AddCommand(fsRmCmd)
+
+	fsCmd.PersistentFlags().StringP("port", "p", "", "port name")
+	fsCmd.PersistentFlags().IntP("baud", "b", 115200, "baud rate")
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	atcom "github.com/sixfab/atcomv2"
+	"github.com/spf13/cobra"
+)
+
+// gnssCmd represents the gnss command
+// It reads the NMEA port of the modem and prints the fix until interrupted
+var gnssCmd = &cobra.Command{
+	Use:   "gnss",
+	Short: "Print the live GNSS fix",
+	Long:  `Read the NMEA port of the modem and print the position whenever it changes`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		port := cmd.Flag("port").Value.String()
+		atPort := cmd.Flag("at-port").Value.String()
+		baud, _ := strconv.Atoi(cmd.Flag("baud").Value.String())
+		enable, _ := strconv.ParseBool(cmd.Flag("enable").Value.String())
+
+		at := atcom.NewAtcom(nil, nil)
+
+		if enable {
+			attr := atcom.DefaultSerialAttr()
+			attr.Port = portOrDetect(at, cmd, atPort)
+
+			if err := at.EnableGNSS(attr); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+
+		if port == "" {
+			modem, err := detectModem(at, cmd)
+
+			if err != nil && err != atcom.ErrATPortMissing {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			detected, err := modem.NMEAPort()
+
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			port = detected
+		}
+
+		attr := atcom.DefaultSerialAttr()
+		attr.Port = port
+		attr.Baud = baud
+
+		reader, err := at.OpenNMEA(attr)
+
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		defer reader.Close()
+
+		last := atcom.Fix{}
+
+		for update := range reader.Updates() {
+			fix := update.Fix
+
+			if fix == last {
+				continue
+			}
+			last = fix
+
+			if fix.Mode == 0 {
+				fmt.Println("waiting for fix")
+				continue
+			}
+
+			fmt.Printf("%s lat %.6f lon %.6f alt %.1fm sats %d hdop %.1f speed %.1fkm/h course %.1f (%dD)\n",
+				fix.Time.Format("2006-01-02 15:04:05"), fix.Latitude, fix.Longitude, fix.Altitude,
+				fix.Satellites, fix.HDOP, fix.Speed, fix.Course, fix.Mode)
+		}
+
+		if err := reader.Err(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(gnssCmd)
+
+	gnssCmd.Flags().StringP("port", "p", "", "NMEA port name")
+	gnssCmd.Flags().IntP("baud", "b", 115200, "baud rate")
+	gnssCmd.Flags().StringP("at-port", "a", "", "AT port name, used with --enable")
+	gnssCmd.Flags().BoolP("enable", "e", false, "switch on the GNSS receiver first")
+}
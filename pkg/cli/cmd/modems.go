@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	atcom "github.com/sixfab/atcomv2"
+	"github.com/spf13/cobra"
+)
+
+// modemsCmd represents the modems command
+var modemsCmd = &cobra.Command{
+	Use:   "modems",
+	Short: "Inspect the modem table",
+	Long:  `Inspect the modems known to the detection`,
+}
+
+// modemsListCmd represents the modems list command
+var modemsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the known modems",
+	Long:  `List the modems known to the detection, including the ones loaded with --modem-db`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		for _, modem := range atcom.SupportedModems() {
+			roles := make([]string, 0, len(modem.Roles))
+			for ifs, role := range modem.Roles {
+				roles = append(roles, ifs+"="+string(role))
+			}
+			sort.Strings(roles)
+
+			fmt.Printf("%s:%s %-18s %-28s at=%s %s\n", modem.VID, modem.PID, modem.Vendor, modem.Product,
+				modem.ATInterface, strings.Join(roles, " "))
+		}
+	},
+}
+
+// loadModemDB registers the modems of the file given with --modem-db
+func loadModemDB(cmd *cobra.Command, args []string) {
+	path := cmd.Flag("modem-db").Value.String()
+
+	if path == "" {
+		return
+	}
+
+	if err := atcom.LoadModems(path); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(modemsCmd)
+	modemsCmd.AddCommand(modemsListCmd)
+
+	rootCmd.PersistentPreRun = loadModemDB
+	rootCmd.PersistentFlags().String("modem-db", "", "YAML or JSON file with additional modem definitions")
+}
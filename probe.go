@@ -0,0 +1,196 @@
+package atcom
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProbeResult describes a serial port examined by ProbePorts
+type ProbeResult struct {
+	Port      string
+	VID       string
+	PID       string
+	Interface string
+	USBPath   string
+	Serial    string
+
+	// Role is known from the modem table or the WWAN port type. DIAG, QMI
+	// and MBIM ports are not probed, AT commands may upset them.
+	Role PortRole
+
+	// InUse is set when another process holds the port, it is not probed then
+	InUse bool
+
+	// Responds is set when the port answered AT with OK
+	Responds     bool
+	Manufacturer string // AT+CGMI
+	Model        string // AT+CGMM
+	Info         string // ATI
+}
+
+// SetProbeUnknown makes DecidePort probe every USB serial port when no
+// supported modem is attached, see ProbePorts. Disabled by default.
+func (t *Atcom) SetProbeUnknown(enabled bool) {
+	t.probeUnknown = enabled
+}
+
+// ProbePorts sends AT, ATI and AT+CGMI;+CGMM to every USB serial port to
+// find AT ports of modems missing in the modem table. Ports held open by
+// another process and ports with a role other than AT are skipped.
+func (t *Atcom) ProbePorts() ([]ProbeResult, error) {
+	ports, err := t.getAvailablePorts()
+
+	if err != nil {
+		return nil, err
+	}
+
+	inUse := portsInUse()
+	results := make([]ProbeResult, 0, len(ports))
+
+	for _, port := range ports {
+		result := ProbeResult{
+			Port:      port["port"],
+			VID:       port["vendor_id"],
+			PID:       port["product_id"],
+			Interface: port["interface"],
+			USBPath:   port["path"],
+			Serial:    port["serial"],
+			Role:      portRole(port),
+		}
+
+		if noProbeRoles[result.Role] {
+			results = append(results, result)
+			continue
+		}
+
+		result.InUse = inUse[result.Port] || portInUse(result.Port)
+
+		if !result.InUse {
+			t.probe(&result)
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// noProbeRoles are the roles of ports that must not receive AT commands
+var noProbeRoles = map[PortRole]bool{RoleDIAG: true, RoleQMI: true, RoleMBIM: true}
+
+// portRole returns the role of a port from getAvailablePorts, known from the
+// modem table or the type of WWAN ports
+func portRole(port map[string]string) PortRole {
+	for _, modem := range SupportedModems() {
+		if modem.VID != port["vendor_id"] || modem.PID != port["product_id"] {
+			continue
+		}
+
+		if role := modem.role(port["interface"]); role != RoleUnknown {
+			return role
+		}
+	}
+
+	if role, ok := wwanTypeRoles[port["type"]]; ok {
+		return role
+	}
+
+	return RoleUnknown
+}
+
+// probe identifies the modem answering on result.Port
+func (t *Atcom) probe(result *ProbeResult) {
+	attr := DefaultSerialAttr()
+	attr.Port = result.Port
+
+	// send returns the response lines without echo and final result
+	send := func(command string) ([]string, bool) {
+		com := NewATCommand(command)
+		com.SerialAttr = attr
+		com.Timeout = 1
+		com = t.SendAT(com)
+
+		if com.Error != nil {
+			return nil, false
+		}
+
+		lines := make([]string, 0)
+		for _, line := range com.Response {
+			if line != "OK" && !strings.HasPrefix(strings.ToUpper(line), "AT") {
+				lines = append(lines, line)
+			}
+		}
+		return lines, true
+	}
+
+	if _, ok := send("AT"); !ok {
+		return
+	}
+
+	result.Responds = true
+
+	if lines, ok := send("ATI"); ok {
+		result.Info = strings.Join(lines, " ")
+	}
+
+	if lines, ok := send("AT+CGMI;+CGMM"); ok {
+		if len(lines) > 0 {
+			result.Manufacturer = strings.TrimSpace(strings.TrimPrefix(lines[0], "+CGMI:"))
+		}
+		if len(lines) > 1 {
+			result.Model = strings.TrimSpace(strings.TrimPrefix(lines[1], "+CGMM:"))
+		}
+	}
+}
+
+// probeModem returns the first responding port found by ProbePorts as modem
+func (t *Atcom) probeModem() (*DetectedModem, error) {
+	results, err := t.ProbePorts()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		if !result.Responds {
+			continue
+		}
+
+		return &DetectedModem{
+			VID:     result.VID,
+			PID:     result.PID,
+			Vendor:  result.Manufacturer,
+			Product: result.Model,
+			USBPath: result.USBPath,
+			Serial:  result.Serial,
+			Port:    result.Port,
+			Interfaces: []DetectedPort{
+				{Port: result.Port, Interface: result.Interface, Role: RoleAT},
+			},
+			Ports: map[PortRole]string{RoleAT: result.Port},
+		}, nil
+	}
+
+	return nil, errors.New("no responding modem found")
+}
+
+// portsInUse returns the device files opened by any process, read from the
+// file descriptors in /proc. Only the processes of the user are visible
+// unless running as root, see portInUse for the others.
+func portsInUse() map[string]bool {
+	inUse := make(map[string]bool)
+
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+
+	for _, fd := range fds {
+		target, err := os.Readlink(fd)
+
+		if err == nil && strings.HasPrefix(target, "/dev/") {
+			inUse[target] = true
+		}
+	}
+
+	return inUse
+}
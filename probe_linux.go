@@ -0,0 +1,27 @@
+//go:build linux
+
+package atcom
+
+import (
+	"errors"
+	"syscall"
+)
+
+// portInUse reports whether another process holds port exclusively, either
+// with TIOCEXCL, which makes opening fail with EBUSY, or with flock
+func portInUse(port string) bool {
+	fd, err := syscall.Open(port, syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+
+	if err != nil {
+		return errors.Is(err, syscall.EBUSY)
+	}
+
+	defer syscall.Close(fd)
+
+	if err := syscall.Flock(fd, syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		return errors.Is(err, syscall.EWOULDBLOCK)
+	}
+
+	syscall.Flock(fd, syscall.LOCK_UN)
+	return false
+}
@@ -0,0 +1,32 @@
+//go:build linux
+
+package atcom
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPortInUse(t *testing.T) {
+	port := filepath.Join(t.TempDir(), "ttyUSB2")
+	require.NoError(t, os.WriteFile(port, nil, 0o600))
+
+	assert.False(t, portInUse(port))
+	assert.False(t, portInUse(filepath.Join(t.TempDir(), "missing")))
+
+	// another open file description holds the lock
+	file, err := os.OpenFile(port, os.O_RDWR, 0)
+	require.NoError(t, err)
+	defer file.Close()
+
+	require.NoError(t, syscall.Flock(int(file.Fd()), syscall.LOCK_EX))
+	assert.True(t, portInUse(port))
+
+	require.NoError(t, syscall.Flock(int(file.Fd()), syscall.LOCK_UN))
+	assert.False(t, portInUse(port))
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package atcom
+
+// portInUse is only available on Linux, ports are assumed to be free
+// otherwise unless a process in /proc holds them
+func portInUse(port string) bool {
+	return false
+}
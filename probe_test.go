@@ -0,0 +1,52 @@
+package atcom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/tarm/serial"
+)
+
+func TestPortRole(t *testing.T) {
+	tests := []struct {
+		name string
+		port map[string]string
+		role PortRole
+	}{
+		{"table at", map[string]string{"vendor_id": "2c7c", "product_id": "0125", "interface": "if02"}, RoleAT},
+		{"table diag", map[string]string{"vendor_id": "2c7c", "product_id": "0125", "interface": "if00"}, RoleDIAG},
+		{"wwan type", map[string]string{"vendor_id": "abcd", "product_id": "0001", "interface": "port7", "type": "QCDM"}, RoleDIAG},
+		{"wwan mbim", map[string]string{"vendor_id": "abcd", "product_id": "0001", "interface": "mbim0", "type": "MBIM"}, RoleMBIM},
+		{"unknown", map[string]string{"vendor_id": "abcd", "product_id": "0001", "interface": "if00"}, RoleUnknown},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.role, portRole(tt.port), tt.name)
+	}
+}
+
+func TestProbePortsSkipsControlPorts(t *testing.T) {
+	modem := newFakeModem()
+
+	at := NewAtcom(modem, nil)
+	at.SetSysfsRoot(fakeSysfs(t))
+
+	results, err := at.ProbePorts()
+	assert.NoError(t, err)
+
+	roles := make(map[string]PortRole)
+	for _, result := range results {
+		roles[result.Port] = result.Role
+		assert.Equal(t, result.Role != RoleDIAG, result.Responds, result.Port)
+	}
+
+	assert.Equal(t, map[string]PortRole{
+		"/dev/ttyACM0": RoleDIAG,
+		"/dev/ttyUSB2": RoleAT,
+		"/dev/ttyUSB3": RoleModem,
+	}, roles)
+	modem.AssertNotCalled(t, "OpenPort", mock.MatchedBy(func(c *serial.Config) bool {
+		return c.Name == "/dev/ttyACM0"
+	}))
+}
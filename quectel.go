@@ -0,0 +1,22 @@
+package atcom
+
+// Quectel gives access to the features specific to Quectel modules
+type Quectel struct {
+	// ContextID is the PDP context used by the TCP/IP stack
+	ContextID int
+
+	at   *Atcom
+	attr SerialAttr
+}
+
+// Quectel returns the Quectel specific API for the modem on attr
+func (t *Atcom) Quectel(attr SerialAttr) *Quectel {
+	return &Quectel{ContextID: 1, at: t, attr: attr}
+}
+
+// command creates a command for the port of the modem
+func (q *Quectel) command(command string) *ATCommand {
+	com := NewATCommand(command)
+	com.SerialAttr = q.attr
+	return com
+}
@@ -0,0 +1,219 @@
+package atcom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// QuectelFile is an entry of the Quectel file system
+type QuectelFile struct {
+	Name string
+	Size int
+}
+
+// quectelChecksum calculates the 16 bit XOR checksum reported by AT+QFUPL
+// and AT+QFDWL
+func quectelChecksum(data []byte) uint16 {
+	var checksum uint16
+
+	for i := 0; i < len(data); i += 2 {
+		word := uint16(data[i]) << 8
+		if i+1 < len(data) {
+			word |= uint16(data[i+1])
+		}
+		checksum ^= word
+	}
+
+	return checksum
+}
+
+// parseTransferResult parses "<size>,<checksum>" of +QFUPL and +QFDWL
+func parseTransferResult(payload string) (int, uint16, error) {
+	params := splitParams(payload)
+
+	if len(params) < 2 {
+		return 0, 0, fmt.Errorf("invalid transfer result: %s", payload)
+	}
+
+	size, err := strconv.Atoi(params[0])
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid transfer result: %s", payload)
+	}
+
+	checksum, err := strconv.ParseUint(params[1], 16, 16)
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid transfer result: %s", payload)
+	}
+
+	return size, uint16(checksum), nil
+}
+
+// ListFiles lists the files matching pattern, e.g. "*", "UFS:*" or "RAM:*"
+func (q *Quectel) ListFiles(pattern string) ([]QuectelFile, error) {
+	if pattern == "" {
+		pattern = "*"
+	}
+
+	com := q.command(fmt.Sprintf("AT+QFLST=\"%s\"", pattern))
+	com = q.at.SendAT(com)
+
+	if com.Error != nil {
+		return nil, com.Error
+	}
+
+	files := make([]QuectelFile, 0)
+
+	for _, line := range linesWithPrefix(com.Response, "+QFLST:") {
+		params := splitParams(line)
+
+		if len(params) < 2 {
+			return nil, fmt.Errorf("invalid response: %s", line)
+		}
+
+		size, err := strconv.Atoi(params[1])
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid response: %s", line)
+		}
+
+		files = append(files, QuectelFile{Name: params[0], Size: size})
+	}
+
+	return files, nil
+}
+
+// transferTimeout estimates the seconds needed to move size bytes over the port
+func (q *Quectel) transferTimeout(size int) int {
+	baud := q.attr.Baud
+	if baud == 0 {
+		baud = 115200
+	}
+
+	return 10 + size*20/baud
+}
+
+// Upload writes data to the file name and verifies the checksum reported
+// by the modem. Files without storage prefix are stored on UFS.
+func (q *Quectel) Upload(name string, data []byte) error {
+	timeout := q.transferTimeout(len(data))
+
+	com := q.command(fmt.Sprintf("AT+QFUPL=\"%s\",%d,%d", name, len(data), timeout))
+	com.Prompt = "CONNECT"
+	com.Data = data
+	com.Timeout = timeout + 5
+	com = q.at.SendAT(com)
+
+	if com.Error != nil {
+		return com.Error
+	}
+
+	for _, line := range linesWithPrefix(com.Response, "+QFUPL:") {
+		size, checksum, err := parseTransferResult(line)
+
+		if err != nil {
+			return err
+		}
+
+		if size != len(data) {
+			return fmt.Errorf("uploaded %d of %d bytes", size, len(data))
+		}
+
+		if checksum != quectelChecksum(data) {
+			return errors.New("checksum mismatch")
+		}
+
+		return nil
+	}
+
+	return errors.New("no upload result in response")
+}
+
+// Download reads the file name and verifies the checksum reported by the modem
+func (q *Quectel) Download(name string) ([]byte, error) {
+	s, err := q.at.acquireSession(q.attr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer q.at.releaseSession(s)
+
+	marker := []byte("\r\n+QFDWL: ")
+	searched := 0
+	var data []byte
+
+	// the file content follows CONNECT and ends right before the +QFDWL
+	// line, whose size tells which marker is the real one
+	reader := func(buf []byte) (int, bool) {
+		for {
+			index := bytes.Index(buf[searched:], marker)
+
+			if index < 0 {
+				searched = max(len(buf)-len(marker), searched)
+				return 0, false
+			}
+
+			index += searched
+			end := bytes.Index(buf[index+len(marker):], []byte("\r\n"))
+
+			if end < 0 {
+				searched = index
+				return 0, false
+			}
+
+			size, _, err := parseTransferResult(string(buf[index+len(marker) : index+len(marker)+end]))
+
+			if err == nil && size == index {
+				data = append([]byte(nil), buf[:index]...)
+				return index, true
+			}
+
+			searched = index + 1
+		}
+	}
+
+	com := q.command(fmt.Sprintf("AT+QFDWL=\"%s\"", name))
+	com.Timeout = 300
+	com = s.exchange(com, func(line string) rawReader {
+		if strings.HasPrefix(line, "CONNECT") {
+			return reader
+		}
+		return nil
+	})
+
+	if com.Error != nil {
+		return nil, com.Error
+	}
+
+	for _, line := range linesWithPrefix(com.Response, "+QFDWL:") {
+		size, checksum, err := parseTransferResult(line)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if size != len(data) {
+			return nil, fmt.Errorf("downloaded %d of %d bytes", len(data), size)
+		}
+
+		if checksum != quectelChecksum(data) {
+			return nil, errors.New("checksum mismatch")
+		}
+
+		return data, nil
+	}
+
+	return nil, errors.New("no download result in response")
+}
+
+// DeleteFile deletes the file name, wildcards like "UFS:*" are allowed
+func (q *Quectel) DeleteFile(name string) error {
+	com := q.command(fmt.Sprintf("AT+QFDEL=\"%s\"", strings.TrimSpace(name)))
+	com = q.at.SendAT(com)
+	return com.Error
+}
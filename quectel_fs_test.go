@@ -0,0 +1,77 @@
+package atcom
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// binary file content that looks like the end of a response
+var quectelFile = []byte("\x00\x01\r\nOK\r\n\r\n+QFDWL: 1,0\r\n\xff")
+
+func TestQuectelChecksum(t *testing.T) {
+	assert.Equal(t, uint16(0x0000), quectelChecksum(nil))
+	assert.Equal(t, uint16(0x0102), quectelChecksum([]byte{0x01, 0x02}))
+	assert.Equal(t, uint16(0x0102^0x0300), quectelChecksum([]byte{0x01, 0x02, 0x03}))
+}
+
+func TestQuectelUpload(t *testing.T) {
+	modem := newFakeModem()
+	modem.on("AT+QFUPL=", "\r\nCONNECT\r\n")
+	modem.onFunc("\x00\x01", func(written string) string {
+		return fmt.Sprintf("\r\n+QFUPL: %d,%x\r\n\r\nOK\r\n", len(written), quectelChecksum([]byte(written)))
+	})
+
+	at := NewAtcom(modem, nil)
+	q := at.Quectel(SerialAttr{Port: "/dev/ttyUSB2"})
+
+	require.NoError(t, q.Upload("UFS:data.bin", quectelFile))
+	assert.Contains(t, modem.commands(), fmt.Sprintf(`AT+QFUPL="UFS:data.bin",%d,10`, len(quectelFile)))
+	assert.Contains(t, modem.commands(), string(quectelFile))
+
+	modem.on("\x00\x01", fmt.Sprintf("\r\n+QFUPL: %d,0\r\n\r\nOK\r\n", len(quectelFile)))
+	assert.EqualError(t, q.Upload("UFS:data.bin", quectelFile), "checksum mismatch")
+
+	modem.on("\x00\x01", "\r\n+QFUPL: 2,1\r\n\r\nOK\r\n")
+	assert.Error(t, q.Upload("UFS:data.bin", quectelFile))
+}
+
+func TestQuectelDownload(t *testing.T) {
+	result := fmt.Sprintf("\r\n+QFDWL: %d,%x\r\n\r\nOK\r\n", len(quectelFile), quectelChecksum(quectelFile))
+
+	modem := newFakeModem()
+	modem.on("AT+QFDWL=", "\r\nCONNECT\r\n"+string(quectelFile)+result)
+
+	at := NewAtcom(modem, nil)
+	q := at.Quectel(SerialAttr{Port: "/dev/ttyUSB2"})
+
+	data, err := q.Download("UFS:data.bin")
+	require.NoError(t, err)
+	assert.Equal(t, quectelFile, data)
+
+	modem.on("AT+QFDWL=", fmt.Sprintf("\r\nCONNECT\r\n%s\r\n+QFDWL: %d,0\r\n\r\nOK\r\n", quectelFile, len(quectelFile)))
+
+	_, err = q.Download("UFS:data.bin")
+	assert.EqualError(t, err, "checksum mismatch")
+}
+
+func TestQuectelListFiles(t *testing.T) {
+	modem := newFakeModem()
+	modem.on("AT+QFLST=", "\r\n+QFLST: \"UFS:a.txt\",12\r\n+QFLST: \"UFS:b.bin\",2048\r\n\r\nOK\r\n")
+
+	at := NewAtcom(modem, nil)
+	q := at.Quectel(SerialAttr{Port: "/dev/ttyUSB2"})
+
+	files, err := q.ListFiles("")
+	require.NoError(t, err)
+
+	assert.Equal(t, []QuectelFile{{"UFS:a.txt", 12}, {"UFS:b.bin", 2048}}, files)
+	assert.Contains(t, modem.commands(), `AT+QFLST="*"`)
+
+	modem.on("AT+QFLST=", "\r\n+QFLST: \"UFS:a.txt\",x\r\n\r\nOK\r\n")
+
+	_, err = q.ListFiles("UFS:*")
+	assert.Error(t, err)
+}
@@ -0,0 +1,274 @@
+package atcom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPResponse is the result of a request made by the HTTP stack of the modem
+type HTTPResponse struct {
+	StatusCode    int
+	ContentLength int64 // -1 when unknown
+
+	// Body streams the response from the modem and must be closed
+	Body io.ReadCloser
+}
+
+// httpTimeout is the time in seconds the modem waits for a server response
+const httpTimeout = 80
+
+// HTTPGet requests url with the HTTP(S) stack of the modem. header may be nil.
+func (q *Quectel) HTTPGet(rawURL string, header http.Header) (*HTTPResponse, error) {
+	return q.httpRequest(http.MethodGet, rawURL, header, nil)
+}
+
+// HTTPPost posts body to url with the HTTP(S) stack of the modem
+func (q *Quectel) HTTPPost(rawURL string, header http.Header, contentType string, body []byte) (*HTTPResponse, error) {
+	if contentType != "" {
+		header = header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Set("Content-Type", contentType)
+	}
+
+	return q.httpRequest(http.MethodPost, rawURL, header, body)
+}
+
+// requestHeader builds the request header sent when custom headers are used
+func requestHeader(method string, target *url.URL, header http.Header, body []byte) []byte {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "%s %s HTTP/1.1\r\nHost: %s\r\n", method, target.RequestURI(), target.Host)
+	header.Write(buf)
+
+	if method == http.MethodPost {
+		fmt.Fprintf(buf, "Content-Length: %d\r\n", len(body))
+	}
+
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+func (q *Quectel) httpRequest(method string, rawURL string, header http.Header, body []byte) (*HTTPResponse, error) {
+	target, err := url.Parse(rawURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := q.at.acquireSession(q.attr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := q.httpExchange(s, method, target, header, body)
+
+	if err != nil {
+		q.at.releaseSession(s)
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// httpExchange sends the request and starts streaming the response body.
+// The session is released once the body has been read.
+func (q *Quectel) httpExchange(s *session, method string, target *url.URL, header http.Header, body []byte) (*HTTPResponse, error) {
+	customHeader := len(header) > 0
+
+	commands := []string{
+		fmt.Sprintf("AT+QHTTPCFG=\"contextid\",%d", q.ContextID),
+		"AT+QHTTPCFG=\"responseheader\",0",
+		fmt.Sprintf("AT+QHTTPCFG=\"requestheader\",%d", map[bool]int{false: 0, true: 1}[customHeader]),
+	}
+
+	if target.Scheme == "https" {
+		commands = append(commands, "AT+QHTTPCFG=\"sslctxid\",1")
+	}
+
+	for _, command := range commands {
+		com := s.sendAT(q.command(command))
+
+		if com.Error != nil {
+			return nil, com.Error
+		}
+	}
+
+	if err := q.activateContext(); err != nil {
+		return nil, err
+	}
+
+	com := q.command(fmt.Sprintf("AT+QHTTPURL=%d,%d", len(target.String()), httpTimeout))
+	com.Prompt = "CONNECT"
+	com.Data = []byte(target.String())
+	com = s.sendAT(com)
+
+	if com.Error != nil {
+		return nil, com.Error
+	}
+
+	// the result of the request is reported with +QHTTPGET or +QHTTPPOST
+	prefix := "+QHTTPGET:"
+	com = q.command(fmt.Sprintf("AT+QHTTPGET=%d", httpTimeout))
+
+	payload := body
+	if customHeader {
+		payload = append(requestHeader(method, target, header, body), body...)
+	}
+
+	switch {
+	case method == http.MethodPost:
+		prefix = "+QHTTPPOST:"
+		com = q.command(fmt.Sprintf("AT+QHTTPPOST=%d,%d,%d", len(payload), httpTimeout, httpTimeout))
+		com.Prompt = "CONNECT"
+		com.Data = payload
+	case customHeader:
+		com = q.command(fmt.Sprintf("AT+QHTTPGET=%d,%d", httpTimeout, len(payload)))
+		com.Prompt = "CONNECT"
+		com.Data = payload
+	}
+
+	results := make(chan string, 1)
+	stop, err := q.at.ListenURC(q.attr, prefix, func(line string) {
+		select {
+		case results <- line:
+		default:
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer stop()
+
+	com.Timeout = httpTimeout + 5
+	com = s.sendAT(com)
+
+	if com.Error != nil {
+		return nil, com.Error
+	}
+
+	// the result may arrive before the final OK
+	for _, line := range linesWithPrefix(com.Response, prefix) {
+		select {
+		case results <- prefix + " " + line:
+		default:
+		}
+	}
+
+	var result string
+
+	select {
+	case result = <-results:
+	case <-time.After((httpTimeout + 5) * time.Second):
+		return nil, errors.New("timeout")
+	}
+
+	// <err>,<httprspcode>,<content_length>
+	params := splitParams(strings.TrimPrefix(result, prefix))
+
+	if params[0] != "0" {
+		return nil, fmt.Errorf("http request failed with error %s", params[0])
+	}
+
+	response := &HTTPResponse{ContentLength: -1}
+
+	if len(params) > 1 {
+		response.StatusCode, _ = strconv.Atoi(params[1])
+	}
+
+	if len(params) > 2 {
+		if length, err := strconv.ParseInt(params[2], 10, 64); err == nil {
+			response.ContentLength = length
+		}
+	}
+
+	reader, writer := io.Pipe()
+	response.Body = reader
+
+	go func() {
+		writer.CloseWithError(q.httpRead(s, writer, response.ContentLength))
+		q.at.releaseSession(s)
+	}()
+
+	return response, nil
+}
+
+// httpRead streams the response body to w with AT+QHTTPREAD. The body
+// follows CONNECT and ends with OK and the +QHTTPREAD result.
+func (q *Quectel) httpRead(s *session, w io.Writer, length int64) error {
+	marker := []byte("\r\nOK\r\n\r\n+QHTTPREAD: ")
+	var written int64
+	var writeErr error
+
+	write := func(data []byte) {
+		if writeErr == nil && len(data) > 0 {
+			_, writeErr = w.Write(data)
+		}
+		written += int64(len(data))
+	}
+
+	reader := func(buf []byte) (int, bool) {
+		searched := 0
+
+		for {
+			index := bytes.Index(buf[searched:], marker)
+
+			if index < 0 {
+				// everything that can not be the start of the marker is body
+				safe := max(len(buf)-len(marker), 0)
+				write(buf[:safe])
+				return safe, false
+			}
+
+			index += searched
+			end := bytes.Index(buf[index+len(marker):], []byte("\r\n"))
+
+			if end < 0 {
+				write(buf[:index])
+				return index, false
+			}
+
+			if _, err := strconv.Atoi(string(buf[index+len(marker) : index+len(marker)+end])); err == nil &&
+				(length < 0 || written+int64(index) == length) {
+				write(buf[:index])
+				// leave the +QHTTPREAD line to the command
+				return index + len("\r\nOK\r\n"), true
+			}
+
+			searched = index + 1
+		}
+	}
+
+	com := q.command(fmt.Sprintf("AT+QHTTPREAD=%d", httpTimeout))
+	com.Desired = []string{"+QHTTPREAD:"}
+	com.Timeout = 300
+	com = s.exchange(com, func(line string) rawReader {
+		if strings.HasPrefix(line, "CONNECT") {
+			return reader
+		}
+		return nil
+	})
+
+	if com.Error != nil {
+		return com.Error
+	}
+
+	for _, line := range linesWithPrefix(com.Response, "+QHTTPREAD:") {
+		if line != "0" {
+			return fmt.Errorf("http read failed with error %s", line)
+		}
+	}
+
+	return writeErr
+}
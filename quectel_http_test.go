@@ -0,0 +1,96 @@
+package atcom
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newHTTPModem returns a modem answering the HTTP(S) commands with result
+// for the request and body for AT+QHTTPREAD
+func newHTTPModem(result string, body string) *fakeModem {
+	modem := newFakeModem()
+	modem.on("AT+QIACT?", "\r\n+QIACT: 1,1,1,\"10.0.0.2\"\r\n\r\nOK\r\n")
+	modem.on("AT+QHTTPURL=", "\r\nCONNECT\r\n")
+	modem.on("http", "\r\nOK\r\n")
+	modem.on("AT+QHTTPGET=", "\r\nOK\r\n\r\n"+result+"\r\n")
+	modem.on("AT+QHTTPPOST=", "\r\nCONNECT\r\n")
+	modem.on("AT+QHTTPREAD=", "\r\nCONNECT\r\n"+body+"\r\nOK\r\n\r\n+QHTTPREAD: 0\r\n")
+
+	return modem
+}
+
+func TestQuectelHTTPGet(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		unknownLength bool
+	}{
+		{"plain", "hello world", false},
+		{"final result in body", "first\r\nOK\r\nsecond", false},
+		{"read result in body", "first\r\nOK\r\n\r\n+QHTTPREAD: 0\r\nsecond", false},
+		{"unknown length", "first\r\nOK\r\nsecond", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			length := strconv.Itoa(len(tt.body))
+			if tt.unknownLength {
+				length = ""
+			}
+
+			modem := newHTTPModem("+QHTTPGET: 0,200,"+length, tt.body)
+
+			at := NewAtcom(modem, nil)
+			response, err := at.Quectel(SerialAttr{Port: "/dev/ttyUSB2"}).HTTPGet("http://example.com/data", nil)
+			require.NoError(t, err)
+
+			body, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			require.NoError(t, response.Body.Close())
+
+			assert.Equal(t, 200, response.StatusCode)
+			assert.Equal(t, tt.body, string(body))
+
+			commands := modem.commands()
+			assert.Contains(t, commands, "AT+QHTTPURL=23,80")
+			assert.Contains(t, commands, "http://example.com/data")
+			assert.Contains(t, commands, "AT+QHTTPGET=80")
+			assert.Contains(t, commands, "AT+QHTTPREAD=80")
+		})
+	}
+}
+
+func TestQuectelHTTPGetError(t *testing.T) {
+	modem := newHTTPModem("+QHTTPGET: 702", "")
+
+	at := NewAtcom(modem, nil)
+	_, err := at.Quectel(SerialAttr{Port: "/dev/ttyUSB2"}).HTTPGet("http://example.com/data", nil)
+	require.Error(t, err)
+
+	assert.Contains(t, err.Error(), "702")
+	assert.NotContains(t, modem.commands(), "AT+QHTTPREAD=80")
+}
+
+func TestQuectelHTTPPost(t *testing.T) {
+	modem := newHTTPModem("", `{"ok":true}`)
+	modem.on("{", "\r\nOK\r\n\r\n+QHTTPPOST: 0,201,11\r\n")
+
+	at := NewAtcom(modem, nil)
+	response, err := at.Quectel(SerialAttr{Port: "/dev/ttyUSB2"}).HTTPPost("http://example.com/data", http.Header{}, "", []byte(`{"id":1}`))
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+
+	assert.Equal(t, 201, response.StatusCode)
+	assert.Equal(t, int64(11), response.ContentLength)
+	assert.Equal(t, `{"ok":true}`, string(body))
+	assert.Contains(t, modem.commands(), "AT+QHTTPPOST=8,80,80")
+	assert.Contains(t, modem.commands(), `{"id":1}`)
+}
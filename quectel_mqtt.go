@@ -0,0 +1,731 @@
+package atcom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MQTTOptions configures the MQTT client of the modem
+type MQTTOptions struct {
+	ClientID     string
+	Username     string
+	Password     string
+	KeepAlive    int // seconds, 120 when zero
+	CleanSession bool
+
+	// TLS uses the SSL context 1 of the modem for the connection
+	TLS bool
+
+	// Reconnect opens the connection again when the modem reports it as lost
+	// and restores the subscriptions
+	Reconnect bool
+
+	// OnEvent receives the connection events
+	OnEvent func(event MQTTEvent)
+}
+
+// MQTTEventType is the kind of an MQTTEvent
+type MQTTEventType int
+
+const (
+	MQTTConnectionLost  MQTTEventType = iota // reported with +QMTSTAT
+	MQTTReconnected                          // connection and subscriptions restored
+	MQTTReconnectFailed                      // a reconnect attempt failed, it is retried
+)
+
+// MQTTEvent reports a change of the connection state
+type MQTTEvent struct {
+	Type MQTTEventType
+
+	// Code is the <err_code> of +QMTSTAT, e.g. 2 when the server did not
+	// answer a keepalive ping
+	Code int
+
+	Err error
+}
+
+// MQTTMessage is a message received on a subscribed topic
+type MQTTMessage struct {
+	Topic   string
+	Payload []byte
+}
+
+// MQTTHandler receives the messages of a subscription
+type MQTTHandler func(msg MQTTMessage)
+
+type mqttSubscription struct {
+	qos     int
+	handler MQTTHandler
+}
+
+// MQTTClient is an MQTT connection made by the MQTT stack of the modem
+type MQTTClient struct {
+	q     *Quectel
+	s     *session
+	id    int
+	host  string
+	port  int
+	opts  MQTTOptions
+	stops []func()
+
+	mu            sync.Mutex
+	subscriptions map[string]mqttSubscription
+	waiters       map[string]chan []string
+	nextMsgID     int
+	connected     bool
+	closed        bool
+
+	// reconnectDelay is the wait before the first reconnect attempt
+	reconnectDelay time.Duration
+
+	// callbacks run in order outside of the session dispatcher, so they are
+	// free to publish
+	tasks  []func()
+	notify chan struct{}
+	done   chan struct{}
+}
+
+// mqttTimeout is the time in seconds to wait for the result of a request
+const mqttTimeout = 75
+
+// mqttStatus describes the <err_code> of +QMTSTAT
+var mqttStatus = map[int]string{
+	1: "connection closed or reset by peer",
+	2: "keepalive ping timed out",
+	3: "connect timed out",
+	4: "connection refused",
+	5: "disconnected by client",
+	6: "packets could not be sent",
+	7: "link not alive or server unavailable",
+}
+
+// MQTTConnect connects to the broker at address ("host:port") with the MQTT
+// stack of the modem
+func (q *Quectel) MQTTConnect(ctx context.Context, address string, opts MQTTOptions) (*MQTTClient, error) {
+	host, portStr, err := net.SplitHostPort(address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := strconv.Atoi(portStr)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %s", portStr)
+	}
+
+	for _, field := range [][2]string{{"client id", opts.ClientID}, {"username", opts.Username}, {"password", opts.Password}} {
+		if err := checkQuotable(field[0], field[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.KeepAlive == 0 {
+		opts.KeepAlive = 120
+	}
+
+	s, err := q.at.acquireSession(q.attr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := s.allocID("mqtt", 0, 5)
+
+	if err != nil {
+		q.at.releaseSession(s)
+		return nil, err
+	}
+
+	c := &MQTTClient{
+		q:              q,
+		s:              s,
+		id:             id,
+		host:           host,
+		port:           port,
+		opts:           opts,
+		subscriptions:  make(map[string]mqttSubscription),
+		waiters:        make(map[string]chan []string),
+		nextMsgID:      1,
+		reconnectDelay: 5 * time.Second,
+		notify:         make(chan struct{}, 1),
+		done:           make(chan struct{}),
+	}
+
+	stop, err := q.at.ListenURC(q.attr, "+QMT", c.onURC)
+
+	if err != nil {
+		c.release()
+		return nil, err
+	}
+
+	c.stops = append(c.stops, stop)
+
+	// payloads may contain anything, they are read by length
+	stop, err = q.at.subscribeRaw(q.attr, "+QMTRECV:", mqttMessageHeader, c.onMessage)
+
+	if err != nil {
+		c.release()
+		return nil, err
+	}
+
+	c.stops = append(c.stops, stop)
+
+	go c.loop()
+
+	if err := c.open(ctx); err != nil {
+		c.release()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// open configures the client, opens the network connection and connects
+func (c *MQTTClient) open(ctx context.Context) error {
+	ssl := "0"
+	if c.opts.TLS {
+		ssl = "1,1"
+	}
+
+	clean := 0
+	if c.opts.CleanSession {
+		clean = 1
+	}
+
+	for _, command := range []string{
+		fmt.Sprintf("AT+QMTCFG=\"version\",%d,4", c.id),
+		fmt.Sprintf("AT+QMTCFG=\"pdpcid\",%d,%d", c.id, c.q.ContextID),
+		fmt.Sprintf("AT+QMTCFG=\"keepalive\",%d,%d", c.id, c.opts.KeepAlive),
+		fmt.Sprintf("AT+QMTCFG=\"session\",%d,%d", c.id, clean),
+		fmt.Sprintf("AT+QMTCFG=\"ssl\",%d,%s", c.id, ssl),
+		// report the payload and its length in +QMTRECV
+		fmt.Sprintf("AT+QMTCFG=\"recv/mode\",%d,0,1", c.id),
+	} {
+		com := c.s.sendAT(c.q.command(command))
+
+		if com.Error != nil {
+			return com.Error
+		}
+	}
+
+	if err := c.q.activateContext(); err != nil {
+		return err
+	}
+
+	// +QMTOPEN: <client_idx>,<result>
+	com := c.q.command(fmt.Sprintf("AT+QMTOPEN=%d,\"%s\",%d", c.id, c.host, c.port))
+	params, err := c.request(ctx, com, "+QMTOPEN", -1)
+
+	if err != nil {
+		return err
+	}
+
+	if params[1] != "0" {
+		return fmt.Errorf("mqtt open failed with error %s", params[1])
+	}
+
+	command := fmt.Sprintf("AT+QMTCONN=%d,\"%s\"", c.id, c.opts.ClientID)
+	if c.opts.Username != "" {
+		command += fmt.Sprintf(",\"%s\",\"%s\"", c.opts.Username, c.opts.Password)
+	}
+
+	// +QMTCONN: <client_idx>,<result>[,<ret_code>]
+	params, err = c.request(ctx, c.q.command(command), "+QMTCONN", -1)
+
+	if err != nil {
+		return err
+	}
+
+	if params[1] != "0" || (len(params) > 2 && params[2] != "0") {
+		return fmt.Errorf("mqtt connect failed: %s", strings.Join(params[1:], ","))
+	}
+
+	c.mu.Lock()
+	c.connected = true
+	subscriptions := make(map[string]mqttSubscription, len(c.subscriptions))
+	for topic, sub := range c.subscriptions {
+		subscriptions[topic] = sub
+	}
+	c.mu.Unlock()
+
+	for topic, sub := range subscriptions {
+		if err := c.subscribe(topic, sub.qos); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// request sends com and waits for its result URC. Results of requests with
+// a message id are matched by msgID as well.
+func (c *MQTTClient) request(ctx context.Context, com *ATCommand, name string, msgID int) ([]string, error) {
+	key := name
+	if msgID >= 0 {
+		key = fmt.Sprintf("%s,%d", name, msgID)
+	}
+
+	results := make(chan []string, 1)
+
+	c.mu.Lock()
+	c.waiters[key] = results
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.waiters, key)
+		c.mu.Unlock()
+	}()
+
+	com = c.s.sendAT(com)
+
+	if com.Error != nil {
+		return nil, com.Error
+	}
+
+	// the result may arrive before the final OK
+	for _, line := range com.Response {
+		if strings.HasPrefix(line, name+":") {
+			c.onURC(line)
+		}
+	}
+
+	select {
+	case params := <-results:
+		return params, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.s.done:
+		return nil, c.s.err
+	case <-time.After(mqttTimeout * time.Second):
+		return nil, errors.New("timeout")
+	}
+}
+
+// onURC handles the +QMT result codes of the client
+func (c *MQTTClient) onURC(line string) {
+	name, payload, _ := strings.Cut(line, ":")
+	params := splitParams(payload)
+
+	if len(params) < 2 || params[0] != strconv.Itoa(c.id) {
+		return
+	}
+
+	switch name {
+	case "+QMTRECV":
+		// handled by onMessage
+	case "+QMTSTAT":
+		code, _ := strconv.Atoi(params[1])
+		c.lost(code)
+	default:
+		// +QMTPUB and +QMTCONN report retransmissions before the result
+		if (name == "+QMTPUB" && len(params) > 2 && params[2] == "1") ||
+			(name == "+QMTCONN" && params[1] == "1") {
+			return
+		}
+
+		c.mu.Lock()
+		results, ok := c.waiters[name+","+params[1]]
+		if !ok {
+			results, ok = c.waiters[name]
+		}
+		c.mu.Unlock()
+
+		if ok {
+			select {
+			case results <- params:
+			default:
+			}
+		}
+	}
+}
+
+// mqttMessageHeader splits +QMTRECV: <client_idx>,<msgid>,<topic>,<payload_len>,"<payload>"
+// after the opening quote of the payload. The reader consumes the payload
+// and its closing quote.
+func mqttMessageHeader(data []byte) (int, rawReader) {
+	quoted := false
+	commas := 0
+
+	for i, b := range data {
+		switch {
+		case b == '"':
+			quoted = !quoted
+		case b == '\n' && !quoted:
+			return -1, nil
+		case b == ',' && !quoted:
+			commas++
+		}
+
+		if commas < 4 {
+			continue
+		}
+
+		if i+1 == len(data) {
+			return 0, nil
+		}
+
+		params := splitParams(strings.TrimPrefix(string(data[:i]), "+QMTRECV:"))
+		length, err := strconv.Atoi(params[len(params)-1])
+
+		if data[i+1] != '"' || err != nil {
+			return -1, nil
+		}
+
+		remaining := length + 1
+
+		return i + 2, func(data []byte) (int, bool) {
+			n := min(len(data), remaining)
+			remaining -= n
+			return n, remaining == 0
+		}
+	}
+
+	return 0, nil
+}
+
+// onMessage delivers a message read with mqttMessageHeader
+func (c *MQTTClient) onMessage(lines []string) {
+	params := splitParams(strings.TrimSuffix(strings.TrimPrefix(lines[0], "+QMTRECV:"), ",\""))
+
+	if len(params) < 4 || params[0] != strconv.Itoa(c.id) {
+		return
+	}
+
+	// the closing quote follows the payload
+	payload := lines[1][:len(lines[1])-1]
+	c.deliver(MQTTMessage{Topic: params[2], Payload: []byte(payload)})
+}
+
+// deliver queues msg for the handlers of the matching subscriptions
+func (c *MQTTClient) deliver(msg MQTTMessage) {
+	c.mu.Lock()
+	handlers := make([]MQTTHandler, 0, 1)
+	for filter, sub := range c.subscriptions {
+		if topicMatches(filter, msg.Topic) {
+			handlers = append(handlers, sub.handler)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, handler := range handlers {
+		c.run(func() { handler(msg) })
+	}
+}
+
+// lost handles a connection closed by the modem
+func (c *MQTTClient) lost(code int) {
+	c.mu.Lock()
+	reconnect := c.connected && c.opts.Reconnect && !c.closed
+	c.connected = false
+	c.mu.Unlock()
+
+	err := errors.New(mqttStatus[code])
+	if mqttStatus[code] == "" {
+		err = fmt.Errorf("mqtt connection closed with error %d", code)
+	}
+
+	c.event(MQTTEvent{Type: MQTTConnectionLost, Code: code, Err: err})
+
+	if reconnect {
+		go c.reconnect()
+	}
+}
+
+// reconnect opens the connection again until it succeeds or the client is
+// disconnected, waiting longer after every failed attempt
+func (c *MQTTClient) reconnect() {
+	c.mu.Lock()
+	delay := c.reconnectDelay
+	c.mu.Unlock()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-time.After(delay):
+		}
+
+		// the network connection may still be open on the modem
+		com := c.q.command(fmt.Sprintf("AT+QMTCLOSE=%d", c.id))
+		c.s.sendAT(com)
+
+		err := c.open(context.Background())
+
+		if err == nil {
+			c.event(MQTTEvent{Type: MQTTReconnected})
+			return
+		}
+
+		c.event(MQTTEvent{Type: MQTTReconnectFailed, Err: err})
+		delay = min(delay*2, 5*time.Minute)
+	}
+}
+
+// event reports event to OnEvent
+func (c *MQTTClient) event(event MQTTEvent) {
+	if c.opts.OnEvent != nil {
+		c.run(func() { c.opts.OnEvent(event) })
+	}
+}
+
+// run queues fn for the callback loop
+func (c *MQTTClient) run(fn func()) {
+	c.mu.Lock()
+	c.tasks = append(c.tasks, fn)
+	c.mu.Unlock()
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (c *MQTTClient) loop() {
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.notify:
+		}
+
+		for {
+			c.mu.Lock()
+			if len(c.tasks) == 0 {
+				c.mu.Unlock()
+				break
+			}
+			task := c.tasks[0]
+			c.tasks = c.tasks[1:]
+			c.mu.Unlock()
+
+			task()
+		}
+	}
+}
+
+// msgID returns the id of the next message, 0 for QoS 0
+func (c *MQTTClient) msgID(qos int) int {
+	if qos == 0 {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.nextMsgID
+	c.nextMsgID = c.nextMsgID%65535 + 1
+	return id
+}
+
+// Connected reports whether the client is connected to the broker
+func (c *MQTTClient) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.connected
+}
+
+// Publish sends payload to topic and waits until the broker accepted it
+// according to qos
+func (c *MQTTClient) Publish(topic string, payload []byte, qos int, retain bool) error {
+	if err := checkQuotable("topic", topic); err != nil {
+		return err
+	}
+
+	if !c.Connected() {
+		return errors.New("mqtt client is not connected")
+	}
+
+	flag := 0
+	if retain {
+		flag = 1
+	}
+
+	id := c.msgID(qos)
+
+	// +QMTPUB: <client_idx>,<msgid>,<result>
+	com := c.q.command(fmt.Sprintf("AT+QMTPUB=%d,%d,%d,%d,\"%s\",%d", c.id, id, qos, flag, topic, len(payload)))
+	// data is only written when not nil
+	com.Data = payload
+	if payload == nil {
+		com.Data = []byte{}
+	}
+	params, err := c.request(context.Background(), com, "+QMTPUB", id)
+
+	if err != nil {
+		return err
+	}
+
+	if len(params) < 3 || params[2] != "0" {
+		return fmt.Errorf("mqtt publish failed: %s", strings.Join(params[1:], ","))
+	}
+
+	return nil
+}
+
+// Subscribe calls handler for the messages on topic, which may contain the
+// wildcards + and #. Subscriptions are restored after a reconnect.
+func (c *MQTTClient) Subscribe(topic string, qos int, handler MQTTHandler) error {
+	if err := checkQuotable("topic", topic); err != nil {
+		return err
+	}
+
+	if !c.Connected() {
+		return errors.New("mqtt client is not connected")
+	}
+
+	c.mu.Lock()
+	previous, existed := c.subscriptions[topic]
+	c.subscriptions[topic] = mqttSubscription{qos: qos, handler: handler}
+	c.mu.Unlock()
+
+	if err := c.subscribe(topic, qos); err != nil {
+		c.mu.Lock()
+		if existed {
+			c.subscriptions[topic] = previous
+		} else {
+			delete(c.subscriptions, topic)
+		}
+		c.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+func (c *MQTTClient) subscribe(topic string, qos int) error {
+	id := c.msgID(1)
+
+	// +QMTSUB: <client_idx>,<msgid>,<result>[,<value>]
+	com := c.q.command(fmt.Sprintf("AT+QMTSUB=%d,%d,\"%s\",%d", c.id, id, topic, qos))
+	params, err := c.request(context.Background(), com, "+QMTSUB", id)
+
+	if err != nil {
+		return err
+	}
+
+	// a granted QoS of 128 means the broker refused the subscription
+	if len(params) < 3 || params[2] != "0" || (len(params) > 3 && params[3] == "128") {
+		return fmt.Errorf("mqtt subscribe failed: %s", strings.Join(params[1:], ","))
+	}
+
+	return nil
+}
+
+// Unsubscribe removes the subscription of topic
+func (c *MQTTClient) Unsubscribe(topic string) error {
+	if err := checkQuotable("topic", topic); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	delete(c.subscriptions, topic)
+	c.mu.Unlock()
+
+	if !c.Connected() {
+		return nil
+	}
+
+	id := c.msgID(1)
+
+	// +QMTUNS: <client_idx>,<msgid>,<result>
+	com := c.q.command(fmt.Sprintf("AT+QMTUNS=%d,%d,\"%s\"", c.id, id, topic))
+	params, err := c.request(context.Background(), com, "+QMTUNS", id)
+
+	if err != nil {
+		return err
+	}
+
+	if len(params) < 3 || params[2] != "0" {
+		return fmt.Errorf("mqtt unsubscribe failed: %s", strings.Join(params[1:], ","))
+	}
+
+	return nil
+}
+
+// Disconnect disconnects from the broker and releases the client
+func (c *MQTTClient) Disconnect() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return errors.New("mqtt client is closed")
+	}
+	c.closed = true
+	connected := c.connected
+	c.connected = false
+	c.mu.Unlock()
+
+	var err error
+
+	if connected {
+		// +QMTDISC: <client_idx>,<result>
+		var params []string
+		params, err = c.request(context.Background(), c.q.command(fmt.Sprintf("AT+QMTDISC=%d", c.id)), "+QMTDISC", -1)
+
+		if err == nil && params[1] != "0" {
+			err = fmt.Errorf("mqtt disconnect failed with error %s", params[1])
+		}
+	} else {
+		c.s.sendAT(c.q.command(fmt.Sprintf("AT+QMTCLOSE=%d", c.id)))
+	}
+
+	c.release()
+	return err
+}
+
+// release stops the client and frees its session resources
+func (c *MQTTClient) release() {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+
+	select {
+	case <-c.done:
+	default:
+		close(c.done)
+	}
+
+	for _, stop := range c.stops {
+		stop()
+	}
+
+	c.s.freeID("mqtt", c.id)
+	c.q.at.releaseSession(c.s)
+}
+
+// checkQuotable returns an error when value can not be sent as a quoted
+// string parameter
+func checkQuotable(name string, value string) error {
+	if strings.Contains(value, "\"") {
+		return fmt.Errorf("mqtt %s must not contain quotes", name)
+	}
+
+	return nil
+}
+
+// topicMatches reports whether topic matches filter with the wildcards + and #
+func topicMatches(filter string, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+
+	for i, level := range filterLevels {
+		switch {
+		case level == "#":
+			return true
+		case i >= len(topicLevels):
+			return false
+		case level != "+" && level != topicLevels[i]:
+			return false
+		}
+	}
+
+	return len(filterLevels) == len(topicLevels)
+}
@@ -0,0 +1,233 @@
+package atcom
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMQTTModem returns a Quectel modem accepting MQTT client 0. Requests
+// with a message id are answered with result, e.g. "0" or "0,1".
+func newMQTTModem() *fakeModem {
+	modem := newFakeModem()
+	modem.on("AT+QIACT?", "\r\n+QIACT: 1,1,1,\"10.0.0.2\"\r\n\r\nOK\r\n")
+	modem.on("AT+QMTOPEN=", "\r\nOK\r\n\r\n+QMTOPEN: 0,0\r\n")
+	modem.on("AT+QMTCONN=", "\r\nOK\r\n\r\n+QMTCONN: 0,0,0\r\n")
+	modem.on("AT+QMTPUB=", "\r\n> ")
+	mqttResult(modem, "AT+QMTSUB=", "+QMTSUB", "0,1")
+	mqttResult(modem, "AT+QMTUNS=", "+QMTUNS", "0")
+	modem.on("AT+QMTDISC=", "\r\nOK\r\n\r\n+QMTDISC: 0,0\r\n")
+
+	return modem
+}
+
+// mqttResult answers the requests starting with prefix with the result URC
+// name for their message id
+func mqttResult(modem *fakeModem, prefix string, name string, result string) {
+	modem.onFunc(prefix, func(written string) string {
+		msgID := strings.Split(written, ",")[1]
+		return fmt.Sprintf("\r\nOK\r\n\r\n%s: 0,%s,%s\r\n", name, msgID, result)
+	})
+}
+
+func mqttConnect(t *testing.T, modem *fakeModem, opts MQTTOptions) *MQTTClient {
+	at := NewAtcom(modem, nil)
+	client, err := at.Quectel(SerialAttr{Port: "/dev/ttyUSB2"}).MQTTConnect(context.Background(), "broker:1883", opts)
+	require.NoError(t, err)
+
+	return client
+}
+
+func TestQuectelMQTTReceive(t *testing.T) {
+	modem := newMQTTModem()
+
+	client := mqttConnect(t, modem, MQTTOptions{ClientID: "test"})
+	defer client.Disconnect()
+
+	received := make(chan MQTTMessage, 2)
+	require.NoError(t, client.Subscribe("sensors/#", 1, func(msg MQTTMessage) {
+		received <- msg
+	}))
+
+	assert.NotContains(t, modem.commands(), "ATE0")
+
+	for _, payload := range []string{
+		`{"name":"a, b","value":"1"}` + "\r\nOK\r\n\"",
+		"\x00\x01\r\n+QMTRECV: 0,2,\"x\",1,\"y\"\r\n",
+	} {
+		modem.emit(fmt.Sprintf("\r\n+QMTRECV: 0,1,\"sensors/1\",%d,\"%s\"\r\n", len(payload), payload))
+
+		select {
+		case msg := <-received:
+			assert.Equal(t, "sensors/1", msg.Topic)
+			assert.Equal(t, payload, string(msg.Payload))
+		case <-time.After(2 * time.Second):
+			t.Fatal("no message")
+		}
+	}
+
+	// messages of other clients are not delivered
+	modem.emit("\r\n+QMTRECV: 1,1,\"sensors/1\",2,\"hi\"\r\n")
+
+	select {
+	case msg := <-received:
+		t.Fatalf("unexpected message %q", msg.Payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestQuectelMQTTPublish(t *testing.T) {
+	modem := newMQTTModem()
+	modem.on("hello", "\r\nOK\r\n\r\n+QMTPUB: 0,0,0\r\n")
+	// retransmissions are reported before the result
+	modem.on("world", "\r\nOK\r\n\r\n+QMTPUB: 0,1,1,1\r\n\r\n+QMTPUB: 0,1,0\r\n")
+	modem.on("lost", "\r\nOK\r\n\r\n+QMTPUB: 0,2,2\r\n")
+
+	client := mqttConnect(t, modem, MQTTOptions{ClientID: "test"})
+	defer client.Disconnect()
+
+	require.NoError(t, client.Publish("sensors/1", []byte("hello"), 0, false))
+	assert.Contains(t, modem.commands(), `AT+QMTPUB=0,0,0,0,"sensors/1",5`)
+
+	require.NoError(t, client.Publish("sensors/1", []byte("world"), 1, true))
+	assert.Contains(t, modem.commands(), `AT+QMTPUB=0,1,1,1,"sensors/1",5`)
+
+	assert.Error(t, client.Publish("sensors/1", []byte("lost"), 1, false))
+
+	// empty payloads are still written after the prompt
+	modem.on("", "\r\nOK\r\n\r\n+QMTPUB: 0,0,0\r\n")
+	modem.on("AT+QMTDISC=", "\r\nOK\r\n\r\n+QMTDISC: 0,0\r\n")
+
+	require.NoError(t, client.Publish("sensors/1", nil, 0, false))
+	assert.Contains(t, modem.commands(), `AT+QMTPUB=0,0,0,0,"sensors/1",0`)
+}
+
+func TestQuectelMQTTSubscribe(t *testing.T) {
+	modem := newMQTTModem()
+
+	client := mqttConnect(t, modem, MQTTOptions{ClientID: "test"})
+	defer client.Disconnect()
+
+	handler := func(MQTTMessage) {}
+
+	mqttResult(modem, "AT+QMTSUB=", "+QMTSUB", "2")
+	assert.Error(t, client.Subscribe("sensors/#", 1, handler))
+
+	// a granted QoS of 128 is a refusal
+	mqttResult(modem, "AT+QMTSUB=", "+QMTSUB", "0,128")
+	assert.Error(t, client.Subscribe("sensors/#", 1, handler))
+	assert.Empty(t, client.subscriptions)
+
+	mqttResult(modem, "AT+QMTSUB=", "+QMTSUB", "0,1")
+	require.NoError(t, client.Subscribe("sensors/#", 1, handler))
+	assert.Contains(t, modem.commands(), `AT+QMTSUB=0,3,"sensors/#",1`)
+	assert.Len(t, client.subscriptions, 1)
+
+	require.NoError(t, client.Unsubscribe("sensors/#"))
+	assert.Contains(t, modem.commands(), `AT+QMTUNS=0,4,"sensors/#"`)
+	assert.Empty(t, client.subscriptions)
+
+	mqttResult(modem, "AT+QMTUNS=", "+QMTUNS", "1")
+	assert.Error(t, client.Unsubscribe("sensors/#"))
+}
+
+func TestQuectelMQTTReconnect(t *testing.T) {
+	modem := newMQTTModem()
+	events := make(chan MQTTEvent, 8)
+
+	client := mqttConnect(t, modem, MQTTOptions{
+		ClientID:  "test",
+		Reconnect: true,
+		OnEvent: func(event MQTTEvent) {
+			events <- event
+		},
+	})
+	defer client.Disconnect()
+
+	client.mu.Lock()
+	client.reconnectDelay = 10 * time.Millisecond
+	client.mu.Unlock()
+
+	require.NoError(t, client.Subscribe("sensors/#", 1, func(MQTTMessage) {}))
+
+	next := func() MQTTEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event")
+			return MQTTEvent{}
+		}
+	}
+
+	// the first attempt fails, the second one restores the subscription
+	modem.on("AT+QMTOPEN=", "\r\nOK\r\n\r\n+QMTOPEN: 0,3\r\n")
+	modem.emit("\r\n+QMTSTAT: 0,2\r\n")
+
+	event := next()
+	assert.Equal(t, MQTTConnectionLost, event.Type)
+	assert.Equal(t, 2, event.Code)
+	assert.EqualError(t, event.Err, "keepalive ping timed out")
+	assert.False(t, client.Connected())
+
+	event = next()
+	assert.Equal(t, MQTTReconnectFailed, event.Type)
+	assert.Error(t, event.Err)
+
+	modem.on("AT+QMTOPEN=", "\r\nOK\r\n\r\n+QMTOPEN: 0,0\r\n")
+
+	assert.Equal(t, MQTTReconnected, next().Type)
+	assert.True(t, client.Connected())
+	assert.Equal(t, 2, countCommand(modem.commands(), "AT+QMTCLOSE=0"))
+	assert.Equal(t, 2, countPrefix(modem.commands(), `AT+QMTSUB=0,`))
+
+	// other clients do not affect the connection
+	modem.emit("\r\n+QMTSTAT: 1,1\r\n")
+
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event %v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestQuectelMQTTQuotes(t *testing.T) {
+	modem := newMQTTModem()
+	at := NewAtcom(modem, nil)
+	q := at.Quectel(SerialAttr{Port: "/dev/ttyUSB2"})
+
+	for _, opts := range []MQTTOptions{
+		{ClientID: `a"b`},
+		{ClientID: "test", Username: `user"`},
+		{ClientID: "test", Username: "user", Password: `"`},
+	} {
+		_, err := q.MQTTConnect(context.Background(), "broker:1883", opts)
+		assert.Error(t, err)
+	}
+	assert.Empty(t, modem.commands())
+
+	client := mqttConnect(t, modem, MQTTOptions{ClientID: "test"})
+	defer client.Disconnect()
+
+	count := len(modem.commands())
+	assert.Error(t, client.Publish(`a"b`, []byte("x"), 0, false))
+	assert.Error(t, client.Subscribe(`a"b`, 0, func(MQTTMessage) {}))
+	assert.Error(t, client.Unsubscribe(`a"b`))
+	assert.Len(t, modem.commands(), count)
+}
+
+// countPrefix counts the commands starting with prefix
+func countPrefix(commands []string, prefix string) int {
+	count := 0
+	for _, command := range commands {
+		if strings.HasPrefix(command, prefix) {
+			count++
+		}
+	}
+	return count
+}
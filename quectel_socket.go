@@ -0,0 +1,215 @@
+package atcom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// quectelSockets drives the Quectel TCP/IP commands in buffer access mode
+type quectelSockets struct {
+	q *Quectel
+	s *session
+}
+
+func (d *quectelSockets) receive(id int) ([]byte, error) {
+	var data []byte
+
+	com := d.q.command(fmt.Sprintf("AT+QIRD=%d,1500", id))
+	com = d.s.exchange(com, func(line string) rawReader {
+		if !strings.HasPrefix(line, "+QIRD:") {
+			return nil
+		}
+
+		size, err := strconv.Atoi(splitParams(strings.TrimPrefix(line, "+QIRD:"))[0])
+
+		if err != nil || size == 0 {
+			return nil
+		}
+
+		// the data follows the +QIRD line
+		return func(buf []byte) (int, bool) {
+			if len(buf) < size {
+				return 0, false
+			}
+
+			data = append([]byte(nil), buf[:size]...)
+			return size, true
+		}
+	})
+
+	return data, com.Error
+}
+
+func (d *quectelSockets) send(id int, data []byte, timeout int) error {
+	com := d.q.command(fmt.Sprintf("AT+QISEND=%d,%d", id, len(data)))
+	com.Data = data
+	com.Desired = []string{"SEND OK"}
+	com.Fault = []string{"SEND FAIL"}
+	com.Timeout = timeout
+	com = d.s.sendAT(com)
+	return com.Error
+}
+
+func (d *quectelSockets) close(id int) error {
+	com := d.q.command(fmt.Sprintf("AT+QICLOSE=%d,10", id))
+	com.Timeout = 15
+	com = d.s.sendAT(com)
+	return com.Error
+}
+
+// activateContext activates the PDP context of the TCP/IP stack if needed
+func (q *Quectel) activateContext() error {
+	com := q.command("AT+QIACT?")
+	com = q.at.SendAT(com)
+
+	if com.Error != nil {
+		return com.Error
+	}
+
+	for _, line := range linesWithPrefix(com.Response, "+QIACT:") {
+		params := splitParams(line)
+
+		if len(params) > 1 && params[0] == strconv.Itoa(q.ContextID) && params[1] == "1" {
+			return nil
+		}
+	}
+
+	com = q.command(fmt.Sprintf("AT+QIACT=%d", q.ContextID))
+	com.Timeout = 150
+	com = q.at.SendAT(com)
+	return com.Error
+}
+
+// Dial connects to address over the TCP/IP stack of the modem. Supported
+// networks are "tcp", "tcp4", "udp" and "udp4".
+func (q *Quectel) Dial(network string, address string) (net.Conn, error) {
+	return q.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to address like Dial, giving up when ctx is done
+func (q *Quectel) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	service := map[string]string{"tcp": "TCP", "tcp4": "TCP", "udp": "UDP", "udp4": "UDP"}[network]
+
+	if service == "" {
+		return nil, fmt.Errorf("unsupported network %s", network)
+	}
+
+	host, portStr, err := net.SplitHostPort(address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := strconv.Atoi(portStr)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %s", portStr)
+	}
+
+	s, err := q.at.acquireSession(q.attr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := s.allocID("connection", 0, 11)
+
+	if err != nil {
+		q.at.releaseSession(s)
+		return nil, err
+	}
+
+	stops := make([]func(), 0, 2)
+	release := func() {
+		for _, stop := range stops {
+			stop()
+		}
+		s.freeID("connection", id)
+		q.at.releaseSession(s)
+	}
+
+	driver := &quectelSockets{q: q, s: s}
+	conn := newSocketConn(driver, id, socketAddr{network, ""}, socketAddr{network, address}, release)
+	opened := make(chan int, 1)
+
+	onOpen := func(line string) {
+		params := splitParams(strings.TrimPrefix(line, "+QIOPEN:"))
+
+		if len(params) > 1 && params[0] == strconv.Itoa(id) {
+			result, _ := strconv.Atoi(params[1])
+			select {
+			case opened <- result:
+			default:
+			}
+		}
+	}
+
+	for prefix, fn := range map[string]func(line string){
+		"+QIOPEN:": onOpen,
+		"+QIURC:": func(line string) {
+			params := splitParams(strings.TrimPrefix(line, "+QIURC:"))
+
+			switch {
+			case len(params) < 2:
+			case params[0] == "pdpdeact" && params[1] == strconv.Itoa(q.ContextID):
+				conn.closedByPeer()
+			case params[1] != strconv.Itoa(id):
+			case params[0] == "recv":
+				conn.dataAvailable()
+			case params[0] == "closed":
+				conn.closedByPeer()
+			}
+		},
+	} {
+		stop, err := q.at.ListenURC(q.attr, prefix, fn)
+
+		if err != nil {
+			release()
+			return nil, err
+		}
+
+		stops = append(stops, stop)
+	}
+
+	if err := q.activateContext(); err != nil {
+		release()
+		return nil, err
+	}
+
+	com := q.command(fmt.Sprintf("AT+QIOPEN=%d,%d,\"%s\",\"%s\",%d,0,0", q.ContextID, id, service, host, port))
+	com = q.at.SendAT(com)
+
+	if com.Error != nil {
+		release()
+		return nil, com.Error
+	}
+
+	// the result may arrive before the final OK
+	for _, line := range com.Response {
+		if strings.HasPrefix(line, "+QIOPEN:") {
+			onOpen(line)
+		}
+	}
+
+	// a failed or unanswered open still holds the connection id until closed
+	select {
+	case result := <-opened:
+		if result == 0 {
+			return conn, nil
+		}
+		err = fmt.Errorf("connection failed with error %d", result)
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-time.After(150 * time.Second):
+		err = errors.New("timeout")
+	}
+
+	driver.close(id)
+	release()
+	return nil, err
+}
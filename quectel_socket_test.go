@@ -0,0 +1,153 @@
+package atcom
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSocketModem returns a Quectel modem accepting connection 0
+func newSocketModem() *fakeModem {
+	modem := newFakeModem()
+	modem.on("AT+QIACT?", "\r\n+QIACT: 1,1,1,\"10.0.0.2\"\r\n\r\nOK\r\n")
+	modem.on("AT+QIOPEN=", "\r\nOK\r\n\r\n+QIOPEN: 0,0\r\n")
+	modem.on("AT+QISEND=", "\r\n> ")
+	modem.on("AT+QIRD=", "\r\n+QIRD: 0\r\n\r\nOK\r\n")
+
+	return modem
+}
+
+func TestQuectelDialFailureClosesConnection(t *testing.T) {
+	modem := newFakeModem()
+	modem.on("AT+QIACT?", "\r\n+QIACT: 1,1,1,\"10.0.0.2\"\r\n\r\nOK\r\n")
+	modem.on("AT+QIOPEN=", "\r\nOK\r\n\r\n+QIOPEN: 0,565\r\n")
+
+	at := NewAtcom(modem, nil)
+	_, err := at.Quectel(SerialAttr{Port: "/dev/ttyUSB2"}).Dial("tcp", "example.com:80")
+	require.Error(t, err)
+
+	commands := modem.commands()
+	assert.Contains(t, commands, "AT+QICLOSE=0,10")
+	assert.NotContains(t, commands, "ATE0")
+}
+
+func TestQuectelSocketReadWrite(t *testing.T) {
+	modem := newSocketModem()
+	modem.on("hello", "\r\nSEND OK\r\n")
+
+	at := NewAtcom(modem, nil)
+	conn, err := at.Quectel(SerialAttr{Port: "/dev/ttyUSB2"}).Dial("tcp", "example.com:80")
+	require.NoError(t, err)
+
+	assert.Contains(t, modem.commands(), `AT+QIOPEN=1,0,"TCP","example.com",80,0,0`)
+	assert.Equal(t, "example.com:80", conn.RemoteAddr().String())
+
+	n, err := conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Contains(t, modem.commands(), "AT+QISEND=0,5")
+	assert.Contains(t, modem.commands(), "hello")
+
+	// the data announced by +QIURC is read with AT+QIRD
+	var reads atomic.Int32
+	modem.onFunc("AT+QIRD=0,1500", func(string) string {
+		if reads.Add(1) == 1 {
+			return "\r\n+QIRD: 8\r\nab\r\nOK\r\n\r\nOK\r\n"
+		}
+		return "\r\n+QIRD: 0\r\n\r\nOK\r\n"
+	})
+	modem.emit("\r\n+QIURC: \"recv\",0\r\n")
+
+	buf := make([]byte, 64)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	n, err = conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ab\r\nOK\r\n", string(buf[:n]))
+
+	// data of other connections is not read
+	modem.emit("\r\n+QIURC: \"recv\",1\r\n")
+	modem.emit("\r\n+QIURC: \"closed\",0\r\n")
+
+	_, err = conn.Read(buf)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.NotContains(t, modem.commands(), "AT+QIRD=1,1500")
+
+	_, err = conn.Write([]byte("hello"))
+	assert.ErrorIs(t, err, net.ErrClosed)
+
+	require.NoError(t, conn.Close())
+	assert.Contains(t, modem.commands(), "AT+QICLOSE=0,10")
+	assert.ErrorIs(t, conn.Close(), net.ErrClosed)
+}
+
+func TestQuectelSocketSendFail(t *testing.T) {
+	modem := newSocketModem()
+	modem.on("hello", "\r\nSEND FAIL\r\n")
+
+	at := NewAtcom(modem, nil)
+	conn, err := at.Quectel(SerialAttr{Port: "/dev/ttyUSB2"}).Dial("udp", "192.0.2.1:5000")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.Contains(t, modem.commands(), `AT+QIOPEN=1,0,"UDP","192.0.2.1",5000,0,0`)
+
+	_, err = conn.Write([]byte("hello"))
+	assert.Error(t, err)
+}
+
+// idleDriver is a socket driver without any data
+type idleDriver struct{}
+
+func (idleDriver) receive(int) ([]byte, error) { return nil, nil }
+func (idleDriver) send(int, []byte, int) error { return nil }
+func (idleDriver) close(int) error             { return nil }
+
+func TestSocketDeadlines(t *testing.T) {
+	conn := newSocketConn(idleDriver{}, 0, socketAddr{"tcp", ""}, socketAddr{"tcp", "example.com:80"}, func() {})
+	defer conn.Close()
+
+	isTimeout := func(err error) bool {
+		var netErr net.Error
+		return errors.As(err, &netErr) && netErr.Timeout()
+	}
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+
+	start := time.Now()
+	_, err := conn.Read(make([]byte, 1))
+	assert.True(t, isTimeout(err), err)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// a deadline set while Read blocks wakes it up
+	done := make(chan error, 1)
+	require.NoError(t, conn.SetReadDeadline(time.Time{}))
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, conn.SetDeadline(time.Now()))
+
+	select {
+	case err := <-done:
+		assert.True(t, isTimeout(err), err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("read did not return")
+	}
+
+	require.NoError(t, conn.SetWriteDeadline(time.Now().Add(-time.Second)))
+	_, err = conn.Write([]byte("hello"))
+	assert.True(t, isTimeout(err), err)
+
+	require.NoError(t, conn.SetWriteDeadline(time.Time{}))
+	n, err := conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+}
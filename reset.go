@@ -0,0 +1,254 @@
+package atcom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ResetMode selects how Reset restarts the modem
+type ResetMode int
+
+const (
+	// ResetReboot restarts the modem with AT+CFUN=1,1 or the vendor command
+	ResetReboot ResetMode = iota
+
+	// ResetPowerDown shuts the modem down with AT+QPOWD, AT#SHDN or AT^SMSO.
+	// It only comes back when the board powers it up again.
+	ResetPowerDown
+)
+
+// resetPollInterval is the interval of the detection while resetting
+const resetPollInterval = 500 * time.Millisecond
+
+// resetTimeout is used when Reset is called without deadline
+const resetTimeout = 120 * time.Second
+
+// resetCommand returns the command restarting a modem of vendor in mode
+func resetCommand(vendor string, mode ResetMode) string {
+	switch {
+	case mode == ResetPowerDown && vendor == "Quectel":
+		return "AT+QPOWD=1"
+	case mode == ResetPowerDown && vendor == "Telit":
+		return "AT#SHDN"
+	case mode == ResetPowerDown:
+		return "AT^SMSO"
+	case vendor == "Telit":
+		return "AT#REBOOT"
+	}
+
+	return "AT+CFUN=1,1"
+}
+
+// Reset restarts the modem on attr and waits until it is back. The port
+// vanishes during the restart and may come back under another name, so the
+// modem is found again by VID/PID and its serial number, or its IMEI when
+// the serial number is unknown. Reset returns once the modem answers AT and
+// the SIM is ready; the returned attributes hold the new port. Without a
+// deadline on ctx it gives up after 120 seconds.
+//
+// Sessions opened on the port, e.g. by ListenURC, must be stopped before.
+func (t *Atcom) Reset(ctx context.Context, attr SerialAttr, mode ResetMode) (SerialAttr, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, resetTimeout)
+		defer cancel()
+	}
+
+	details, err := t.portDetails(attr.Port)
+
+	if err != nil {
+		return attr, err
+	}
+
+	imei, err := t.imei(attr)
+
+	if err != nil {
+		return attr, err
+	}
+
+	vendor, err := t.vendor(attr)
+
+	if err != nil {
+		return attr, err
+	}
+
+	com := NewATCommand(resetCommand(vendor, mode))
+	com.SerialAttr = attr
+	com.Timeout = 10
+	com = t.SendAT(com)
+
+	// timeouts and read errors are expected when the port vanishes
+	if com.Error != nil && len(com.Response) > 0 && strings.Contains(com.Response[len(com.Response)-1], "ERROR") {
+		return attr, com.Error
+	}
+
+	if err := t.waitForDetach(ctx, details); err != nil {
+		return attr, err
+	}
+
+	port, err := t.waitForAttach(ctx, details, imei, attr.Baud)
+
+	if err != nil {
+		return attr, err
+	}
+
+	attr.Port = port
+
+	if err := t.waitForSIM(ctx, attr); err != nil {
+		return attr, err
+	}
+
+	return attr, nil
+}
+
+// portDetails returns the detection details of port
+func (t *Atcom) portDetails(port string) (map[string]string, error) {
+	ports, err := t.getAvailablePorts()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, details := range ports {
+		if details["port"] == port {
+			return details, nil
+		}
+	}
+
+	return nil, fmt.Errorf("port %s not detected", port)
+}
+
+// imei reads the IMEI of the modem on attr with AT+CGSN
+func (t *Atcom) imei(attr SerialAttr) (string, error) {
+	com := NewATCommand("AT+CGSN")
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+
+	if com.Error != nil {
+		return "", com.Error
+	}
+
+	for _, line := range com.Response {
+		line = strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "+CGSN:")), "\"")
+
+		if len(line) >= 14 && strings.Trim(line, "0123456789") == "" {
+			return line, nil
+		}
+	}
+
+	return "", errors.New("no imei in response")
+}
+
+// sameDevice reports whether the detected port belongs to the USB device
+// described by details
+func sameDevice(details map[string]string, port map[string]string) bool {
+	if port["vendor_id"] != details["vendor_id"] || port["product_id"] != details["product_id"] {
+		return false
+	}
+
+	if details["serial"] != "" {
+		return port["serial"] == details["serial"]
+	}
+
+	return true
+}
+
+// waitForDetach waits until the ports of the modem are gone
+func (t *Atcom) waitForDetach(ctx context.Context, details map[string]string) error {
+	for {
+		ports, err := t.getAvailablePorts()
+
+		if err != nil {
+			return err
+		}
+
+		present := false
+		for _, port := range ports {
+			// without serial number the port name identifies the modem
+			if sameDevice(details, port) && (details["serial"] != "" || port["port"] == details["port"]) {
+				present = true
+			}
+		}
+
+		if !present {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(resetPollInterval):
+		}
+	}
+}
+
+// waitForAttach waits for the port on the interface of the original port of
+// the modem and returns its name once it answers AT
+func (t *Atcom) waitForAttach(ctx context.Context, details map[string]string, imei string, baud int) (string, error) {
+	for {
+		ports, err := t.getAvailablePorts()
+
+		if err != nil {
+			return "", err
+		}
+
+		for _, port := range ports {
+			if !sameDevice(details, port) || port["interface"] != details["interface"] {
+				continue
+			}
+
+			attr := DefaultSerialAttr()
+			attr.Port = port["port"]
+			attr.Baud = baud
+
+			com := NewATCommand("AT")
+			com.SerialAttr = attr
+			com.Timeout = 1
+			com = t.SendAT(com)
+
+			if com.Error != nil {
+				continue
+			}
+
+			// another modem of the same type answered
+			if details["serial"] == "" {
+				if found, err := t.imei(attr); err != nil || found != imei {
+					continue
+				}
+			}
+
+			return attr.Port, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(resetPollInterval):
+		}
+	}
+}
+
+// waitForSIM waits until the SIM is initialized. States asking for a code
+// are returned as error.
+func (t *Atcom) waitForSIM(ctx context.Context, attr SerialAttr) error {
+	for {
+		state, err := t.SIMState(attr)
+
+		switch {
+		case err != nil, state == SIMBusy:
+		case state == SIMReady:
+			return nil
+		default:
+			return fmt.Errorf("sim not ready: %s", state)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(resetPollInterval):
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package atcom
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSameDevice(t *testing.T) {
+	details := map[string]string{"vendor_id": "2c7c", "product_id": "0125", "serial": "0123456789"}
+
+	tests := []struct {
+		name    string
+		details map[string]string
+		port    map[string]string
+		same    bool
+	}{
+		{"same serial", details, map[string]string{"vendor_id": "2c7c", "product_id": "0125", "serial": "0123456789"}, true},
+		{"other serial", details, map[string]string{"vendor_id": "2c7c", "product_id": "0125", "serial": "9876543210"}, false},
+		{"other product", details, map[string]string{"vendor_id": "2c7c", "product_id": "0800", "serial": "0123456789"}, false},
+		{"no serial", map[string]string{"vendor_id": "2c7c", "product_id": "0125"}, map[string]string{"vendor_id": "2c7c", "product_id": "0125", "serial": "x"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.same, sameDevice(tt.details, tt.port))
+		})
+	}
+}
+
+func TestWaitForDetach(t *testing.T) {
+	root := fakeSysfs(t)
+
+	at := NewAtcom(nil, nil)
+	at.SetSysfsRoot(root)
+
+	details, err := at.portDetails("/dev/ttyUSB2")
+	require.NoError(t, err)
+
+	// the wait only ends with ctx while the modem is present
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, at.waitForDetach(ctx, details), context.DeadlineExceeded)
+
+	for _, tty := range []string{"ttyUSB2", "ttyUSB3", "ttyACM0"} {
+		require.NoError(t, os.Remove(filepath.Join(root, "class/tty", tty)))
+	}
+
+	require.NoError(t, at.waitForDetach(context.Background(), details))
+}
+
+func TestWaitForAttach(t *testing.T) {
+	modem := newFakeModem()
+	modem.on("AT+CGSN", "\r\n866123456789012\r\n\r\nOK\r\n")
+
+	at := NewAtcom(modem, nil)
+	at.SetSysfsRoot(fakeSysfs(t))
+
+	details, err := at.portDetails("/dev/ttyUSB2")
+	require.NoError(t, err)
+
+	port, err := at.waitForAttach(context.Background(), details, "866123456789012", 115200)
+	require.NoError(t, err)
+	assert.Equal(t, "/dev/ttyUSB2", port)
+
+	// without serial number the IMEI tells modems of the same type apart
+	delete(details, "serial")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = at.waitForAttach(ctx, details, "866000000000000", 115200)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWaitForSIM(t *testing.T) {
+	modem := newFakeModem()
+
+	var polls atomic.Int32
+	modem.onFunc("AT+CPIN?", func(string) string {
+		if polls.Add(1) == 1 {
+			return "\r\n+CME ERROR: 14\r\n"
+		}
+		return "\r\n+CPIN: READY\r\n\r\nOK\r\n"
+	})
+
+	at := NewAtcom(modem, nil)
+	attr := SerialAttr{Port: "/dev/ttyUSB2"}
+
+	require.NoError(t, at.waitForSIM(context.Background(), attr))
+	assert.Equal(t, int32(2), polls.Load())
+
+	modem.on("AT+CPIN?", "\r\n+CPIN: SIM PIN\r\n\r\nOK\r\n")
+	assert.EqualError(t, at.waitForSIM(context.Background(), attr), "sim not ready: SIM PIN")
+
+	modem.on("AT+CPIN?", "\r\n+CME ERROR: 14\r\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, at.waitForSIM(ctx, attr), context.DeadlineExceeded)
+}
@@ -0,0 +1,193 @@
+package atcom
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ModemSelector picks one of several attached modems. Empty fields match
+// every modem; Index then selects among the matching modems, ordered by
+// USB path.
+type ModemSelector struct {
+	Serial  string // USB serial number
+	USBPath string // sysfs path of the USB device, or its last element like 1-1.3
+	IMEI    string // read with AT+CGSN from the AT port
+	Index   int
+}
+
+// DetectAll detects every attached supported modem, ordered by USB path.
+// Modems without AT port are included with an empty Port.
+func (t *Atcom) DetectAll() ([]*DetectedModem, error) {
+	ports, err := t.getAvailablePorts()
+
+	if err != nil {
+		return nil, err
+	}
+
+	modems := make([]*DetectedModem, 0)
+	seen := make(map[string]bool)
+
+	for _, modem := range SupportedModems() {
+		// group the ports by USB device
+		devices := make(map[string][]map[string]string)
+
+		for _, port := range ports {
+			if port["vendor_id"] == modem.VID && port["product_id"] == modem.PID {
+				devices[port["path"]] = append(devices[port["path"]], port)
+			}
+		}
+
+		for path, devicePorts := range devices {
+			detected, _ := detectedModem(modem, devicePorts)
+			modems = append(modems, detected)
+			seen[path] = true
+		}
+	}
+
+	// modems whose serial driver is missing have no ports at all
+	if devices, err := t.usbDevices(); err == nil {
+		if pci, err := t.pciDevices(); err == nil {
+			devices = append(devices, pci...)
+		}
+
+		for _, device := range devices {
+			for _, modem := range SupportedModems() {
+				if device.vid == modem.VID && device.pid == modem.PID && !seen[device.path] {
+					detected, _ := detectedModem(modem, nil)
+					detected.USBPath = device.path
+					detected.Serial = device.serial
+					modems = append(modems, detected)
+				}
+			}
+		}
+	}
+
+	sort.Slice(modems, func(i, j int) bool {
+		return lessUSBPath(modems[i].USBPath, modems[j].USBPath)
+	})
+
+	return modems, nil
+}
+
+// lessUSBPath orders USB paths with their port numbers compared by value,
+// so 1-1.2 comes before 1-1.10
+func lessUSBPath(a string, b string) bool {
+	for a != "" && b != "" {
+		x, y := pathToken(a), pathToken(b)
+
+		if x != y {
+			nx, errX := strconv.Atoi(x)
+			ny, errY := strconv.Atoi(y)
+
+			if errX == nil && errY == nil && nx != ny {
+				return nx < ny
+			}
+			return x < y
+		}
+
+		a, b = a[len(x):], b[len(y):]
+	}
+
+	return len(a) < len(b)
+}
+
+// pathToken returns the leading run of digits or of other characters of path
+func pathToken(path string) string {
+	digit := func(c byte) bool { return c >= '0' && c <= '9' }
+
+	end := 1
+	for end < len(path) && digit(path[end]) == digit(path[0]) {
+		end++
+	}
+
+	return path[:end]
+}
+
+// SelectModem detects the attached modems and returns the one matching sel
+func (t *Atcom) SelectModem(sel ModemSelector) (*DetectedModem, error) {
+	modems, err := t.DetectAll()
+
+	if err != nil {
+		return nil, err
+	}
+
+	matching := make([]*DetectedModem, 0)
+
+	for _, modem := range modems {
+		if sel.Serial != "" && modem.Serial != sel.Serial {
+			continue
+		}
+
+		if sel.USBPath != "" && modem.USBPath != sel.USBPath && !strings.HasSuffix(modem.USBPath, "/"+sel.USBPath) {
+			continue
+		}
+
+		if sel.IMEI != "" {
+			if modem.Port == "" {
+				continue
+			}
+
+			attr := DefaultSerialAttr()
+			attr.Port = modem.Port
+
+			if imei, err := t.imei(attr); err != nil || imei != sel.IMEI {
+				continue
+			}
+		}
+
+		matching = append(matching, modem)
+	}
+
+	if sel.Index < 0 || sel.Index >= len(matching) {
+		return nil, errors.New("no matching modem found")
+	}
+
+	modem := matching[sel.Index]
+
+	if modem.Port == "" {
+		return modem, ErrATPortMissing
+	}
+
+	return modem, nil
+}
+
+// ParseModemSelector parses a selector given as serial:<serial>,
+// path:<usb path>, imei:<imei> or index:<n>. A plain number is an index.
+func ParseModemSelector(value string) (ModemSelector, error) {
+	sel := ModemSelector{}
+	key, arg, found := strings.Cut(value, ":")
+
+	if !found {
+		index, err := strconv.Atoi(value)
+
+		if err != nil {
+			return sel, fmt.Errorf("invalid modem selector: %s", value)
+		}
+
+		sel.Index = index
+		return sel, nil
+	}
+
+	switch key {
+	case "serial":
+		sel.Serial = arg
+	case "path":
+		sel.USBPath = arg
+	case "imei":
+		sel.IMEI = arg
+	case "index":
+		index, err := strconv.Atoi(arg)
+
+		if err != nil {
+			return sel, fmt.Errorf("invalid modem index: %s", arg)
+		}
+		sel.Index = index
+	default:
+		return sel, fmt.Errorf("invalid modem selector: %s", value)
+	}
+
+	return sel, nil
+}
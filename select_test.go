@@ -0,0 +1,39 @@
+package atcom
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLessUSBPath(t *testing.T) {
+	paths := []string{
+		"/devices/platform/scb/usb1/1-1.10",
+		"/devices/platform/scb/usb2/2-1",
+		"/devices/platform/scb/usb1/1-1.2",
+		"/devices/platform/scb/usb1/1-1.1.3",
+		"/devices/platform/scb/usb1/1-1",
+		"/devices/platform/scb/usb1/1-10",
+		"/devices/platform/scb/usb1/1-2",
+		"/devices/platform/scb/usb1/1-1.1",
+	}
+
+	sort.Slice(paths, func(i, j int) bool {
+		return lessUSBPath(paths[i], paths[j])
+	})
+
+	assert.Equal(t, []string{
+		"/devices/platform/scb/usb1/1-1",
+		"/devices/platform/scb/usb1/1-1.1",
+		"/devices/platform/scb/usb1/1-1.1.3",
+		"/devices/platform/scb/usb1/1-1.2",
+		"/devices/platform/scb/usb1/1-1.10",
+		"/devices/platform/scb/usb1/1-2",
+		"/devices/platform/scb/usb1/1-10",
+		"/devices/platform/scb/usb2/2-1",
+	}, paths)
+
+	assert.False(t, lessUSBPath("/devices/usb1/1-1", "/devices/usb1/1-1"))
+	assert.False(t, lessUSBPath("", ""))
+}
@@ -0,0 +1,636 @@
+package atcom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// session keeps a serial port open so that unsolicited result codes can be
+// received while commands are sent. While a session is open on a port,
+// SendAT routes the commands of that port through it.
+type session struct {
+	at   *Atcom
+	attr SerialAttr
+	port *serial.Port
+	refs int // guarded by Atcom.mu
+
+	// serializes commands
+	cmdMu sync.Mutex
+
+	mu        sync.Mutex
+	command   *pendingCommand
+	handlers  map[int]*urcHandler
+	nextID    int
+	collector []*urcHandler // handlers waiting for the extra lines of a URC
+	collected []string
+	remaining int
+	raw       rawReader
+	ids       map[string]map[int]bool // ids in use per kind, see allocID
+	events    []urcEvent
+	err       error
+
+	notify chan struct{}
+	done   chan struct{}
+}
+
+type urcHandler struct {
+	prefix string
+	extra  int // number of lines following the URC that belong to it
+	raw    rawHeader
+	fn     func(lines []string)
+}
+
+type urcEvent struct {
+	handlers []*urcHandler
+	lines    []string
+}
+
+// rawReader consumes binary data following a response line. It returns the
+// number of bytes used and whether the binary data is complete.
+type rawReader func(data []byte) (n int, done bool)
+
+// rawHeader splits a URC carrying binary data. It returns the length of the
+// URC header at the start of data and a reader for the data following it,
+// 0 while the header is incomplete and -1 when the URC has no binary data.
+type rawHeader func(data []byte) (n int, reader rawReader)
+
+type pendingCommand struct {
+	prefix string // lines with this prefix answer the command even if a URC handler matches
+	prompt string // reported even when the modem does not end the line
+	lines  chan string
+	done   chan struct{}
+
+	// raw, when set, may return a reader for the binary data after a line
+	raw func(line string) rawReader
+}
+
+// commandPrefix returns the response prefix of a command, e.g. +CMGR for AT+CMGR=3
+func commandPrefix(command string) string {
+	if len(command) < 3 || !strings.EqualFold(command[:2], "AT") {
+		return ""
+	}
+
+	command = command[2:]
+
+	if !strings.ContainsAny(command[:1], "+#$^") {
+		return ""
+	}
+
+	if end := strings.IndexAny(command, "=?;"); end >= 0 {
+		command = command[:end]
+	}
+
+	return strings.ToUpper(command)
+}
+
+// activeSession returns the open session of port or nil
+func (t *Atcom) activeSession(port string) *session {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.sessions[port]
+}
+
+// acquireSession returns the session of attr.Port and opens it when needed.
+// Every successful call must be paired with releaseSession.
+func (t *Atcom) acquireSession(attr SerialAttr) (*session, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if s, ok := t.sessions[attr.Port]; ok {
+		s.refs++
+		return s, nil
+	}
+
+	port, err := t.open(attr.Port, attr.Baud)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s := &session{
+		at:       t,
+		attr:     attr,
+		port:     port,
+		refs:     1,
+		handlers: make(map[int]*urcHandler),
+		notify:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+
+	if t.sessions == nil {
+		t.sessions = make(map[string]*session)
+	}
+	t.sessions[attr.Port] = s
+
+	go s.readLoop()
+	go s.dispatchLoop()
+
+	return s, nil
+}
+
+// releaseSession closes the session when its last user is gone. The
+// session is removed in the same critical section, so acquireSession never
+// returns a session that is being closed.
+func (t *Atcom) releaseSession(s *session) {
+	t.mu.Lock()
+	s.refs--
+	last := s.refs == 0
+
+	if last && t.sessions[s.attr.Port] == s {
+		delete(t.sessions, s.attr.Port)
+	}
+	t.mu.Unlock()
+
+	if last {
+		s.close(errors.New("session closed"))
+	}
+}
+
+// subscribe registers fn for the URCs starting with prefix on the port of attr
+func (t *Atcom) subscribe(attr SerialAttr, prefix string, extra int, fn func(lines []string)) (stop func(), err error) {
+	return t.addHandler(attr, &urcHandler{prefix: prefix, extra: extra, fn: fn})
+}
+
+// subscribeRaw registers fn for the URCs starting with prefix that are
+// followed by binary data, see rawHeader. fn receives the header and the
+// data read.
+func (t *Atcom) subscribeRaw(attr SerialAttr, prefix string, raw rawHeader, fn func(lines []string)) (stop func(), err error) {
+	return t.addHandler(attr, &urcHandler{prefix: prefix, extra: 1, raw: raw, fn: fn})
+}
+
+func (t *Atcom) addHandler(attr SerialAttr, handler *urcHandler) (stop func(), err error) {
+	s, err := t.acquireSession(attr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	id := s.nextID
+	s.nextID++
+	s.handlers[id] = handler
+	s.mu.Unlock()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.handlers, id)
+			s.mu.Unlock()
+			t.releaseSession(s)
+		})
+	}, nil
+}
+
+// ListenURC calls fn for every unsolicited result code starting with prefix
+// received on the port of attr. The port is kept open until stop is called.
+func (t *Atcom) ListenURC(attr SerialAttr, prefix string, fn func(line string)) (stop func(), err error) {
+	return t.subscribe(attr, prefix, 0, func(lines []string) {
+		fn(lines[0])
+	})
+}
+
+// close stops the session and reports err to a pending command
+func (s *session) close(err error) {
+	s.at.mu.Lock()
+	if s.at.sessions[s.attr.Port] == s {
+		delete(s.at.sessions, s.attr.Port)
+	}
+	s.at.mu.Unlock()
+
+	s.mu.Lock()
+
+	if s.err != nil {
+		s.mu.Unlock()
+		return
+	}
+
+	s.err = err
+	close(s.done)
+	s.mu.Unlock()
+
+	s.at.serial.Close(s.port)
+}
+
+func (s *session) readLoop() {
+	buf := make([]byte, 1024)
+	data := make([]byte, 0)
+
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		n, err := s.at.serial.Read(s.port, buf)
+
+		if err != nil {
+			if err.Error() == "EOF" {
+				time.Sleep(time.Millisecond * 5)
+				continue
+			}
+
+			s.close(err)
+			return
+		}
+
+		data = s.consume(append(data, buf[:n]...))
+	}
+}
+
+// consume handles the complete lines and raw transfers in data and returns
+// the bytes that have to wait for more input
+func (s *session) consume(data []byte) []byte {
+	for len(data) > 0 {
+		s.mu.Lock()
+		raw := s.raw
+		command := s.command
+		s.mu.Unlock()
+
+		if raw != nil {
+			n, done := raw(data)
+			data = data[n:]
+
+			if done {
+				s.mu.Lock()
+				s.raw = nil
+				s.mu.Unlock()
+				continue
+			}
+			return data
+		}
+
+		n, ok := s.readRawURC(data)
+
+		if !ok {
+			return data
+		}
+
+		if n > 0 {
+			data = data[n:]
+			continue
+		}
+
+		end := bytes.IndexByte(data, '\n')
+
+		if end < 0 {
+			// prompts like "> " are not terminated
+			line := strings.TrimSpace(string(data))
+
+			if command != nil && command.prompt != "" && strings.HasPrefix(line, command.prompt) {
+				s.dispatch(line)
+				return data[:0]
+			}
+			return data
+		}
+
+		// quoted strings like USSD menus may span several lines
+		for lines := 1; bytes.Count(data[:end], []byte{'"'})%2 != 0 && lines < 16; lines++ {
+			next := bytes.IndexByte(data[end+1:], '\n')
+
+			if next < 0 {
+				return data
+			}
+			end += next + 1
+		}
+
+		line := strings.TrimSpace(strings.ReplaceAll(string(data[:end]), "\r\n", "\n"))
+		data = data[end+1:]
+
+		if line == "" {
+			continue
+		}
+
+		s.dispatch(line)
+
+		if command != nil && command.raw != nil {
+			if reader := command.raw(line); reader != nil {
+				s.mu.Lock()
+				s.raw = reader
+				s.mu.Unlock()
+			}
+		}
+	}
+
+	return data
+}
+
+// readRawURC starts reading a URC with binary data at the start of data. It
+// returns the length of its header, 0 if data starts with a different line
+// and false while the header is incomplete.
+func (s *session) readRawURC(data []byte) (int, bool) {
+	s.mu.Lock()
+	handlers := make([]*urcHandler, 0)
+	for _, handler := range s.handlers {
+		if handler.raw != nil && bytes.HasPrefix(data, []byte(handler.prefix)) {
+			handlers = append(handlers, handler)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(handlers) == 0 {
+		return 0, true
+	}
+
+	n, reader := handlers[0].raw(data)
+
+	if n <= 0 {
+		return 0, n < 0
+	}
+
+	header := strings.TrimSpace(string(data[:n]))
+	read := make([]byte, 0)
+
+	s.mu.Lock()
+	s.raw = func(data []byte) (int, bool) {
+		n, done := reader(data)
+		read = append(read, data[:n]...)
+
+		if done {
+			s.mu.Lock()
+			s.queue(urcEvent{handlers: handlers, lines: []string{header, string(read)}})
+			s.mu.Unlock()
+		}
+		return n, done
+	}
+	s.mu.Unlock()
+
+	return n, true
+}
+
+// dispatch hands a line to the pending command or to the URC handlers
+func (s *session) dispatch(line string) {
+	s.mu.Lock()
+
+	if s.collector != nil {
+		s.collected = append(s.collected, line)
+		s.remaining--
+
+		if s.remaining == 0 {
+			s.queue(urcEvent{handlers: s.collector, lines: s.collected})
+			s.collector = nil
+		}
+		s.mu.Unlock()
+		return
+	}
+
+	command := s.command
+	s.mu.Unlock()
+
+	if command != nil && (!s.isURC(line) || (command.prefix != "" && strings.HasPrefix(line, command.prefix))) {
+		select {
+		case command.lines <- line:
+			return
+		case <-command.done:
+			// the command completed meanwhile, the line is unsolicited
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matched := make([]*urcHandler, 0)
+	extra := 0
+
+	for _, handler := range s.handlers {
+		// handlers of binary data only receive URCs read by readRawURC
+		if handler.raw == nil && strings.HasPrefix(line, handler.prefix) {
+			matched = append(matched, handler)
+			extra = max(extra, handler.extra)
+		}
+	}
+
+	switch {
+	case len(matched) == 0:
+		return
+	case extra > 0:
+		s.collector = matched
+		s.collected = []string{line}
+		s.remaining = extra
+	default:
+		s.queue(urcEvent{handlers: matched, lines: []string{line}})
+	}
+}
+
+// isURC reports whether a URC handler is registered for line
+func (s *session) isURC(line string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, handler := range s.handlers {
+		if strings.HasPrefix(line, handler.prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// queue appends an event for the dispatch loop, s.mu must be held
+func (s *session) queue(event urcEvent) {
+	s.events = append(s.events, event)
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// dispatchLoop calls the URC handlers outside of the read loop, so handlers
+// are free to send commands themselves
+func (s *session) dispatchLoop() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.notify:
+		}
+
+		for {
+			s.mu.Lock()
+			if len(s.events) == 0 {
+				s.mu.Unlock()
+				break
+			}
+			event := s.events[0]
+			s.events = s.events[1:]
+			s.mu.Unlock()
+
+			for _, handler := range event.handlers {
+				lines := event.lines
+				if len(lines) > handler.extra+1 {
+					lines = lines[:handler.extra+1]
+				}
+				handler.fn(lines)
+			}
+		}
+	}
+}
+
+// allocID reserves the lowest free id of kind between first and last, e.g.
+// socket connection ids. Every kind has its own range of ids.
+func (s *session) allocID(kind string, first int, last int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ids == nil {
+		s.ids = make(map[string]map[int]bool)
+	}
+
+	if s.ids[kind] == nil {
+		s.ids[kind] = make(map[int]bool)
+	}
+
+	for id := first; id <= last; id++ {
+		if !s.ids[kind][id] {
+			s.ids[kind][id] = true
+			return id, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no free %s id", kind)
+}
+
+// freeID releases an id reserved with allocID
+func (s *session) freeID(kind string, id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.ids[kind], id)
+}
+
+// write sends raw bytes to the port of the session
+func (s *session) write(data []byte) error {
+	_, err := s.at.serial.Write(s.port, data)
+	return err
+}
+
+// sendAT is the session counterpart of Atcom.SendAT
+func (s *session) sendAT(c *ATCommand) *ATCommand {
+	return s.exchange(c, nil)
+}
+
+// exchange sends c and collects its response. raw optionally provides a
+// reader for binary data following a response line.
+func (s *session) exchange(c *ATCommand, raw func(line string) rawReader) *ATCommand {
+	s.cmdMu.Lock()
+	defer s.cmdMu.Unlock()
+
+	prompt := c.Prompt
+	if c.Data != nil && prompt == "" {
+		prompt = ">"
+	}
+
+	command := &pendingCommand{
+		prefix: commandPrefix(c.Command),
+		prompt: prompt,
+		lines:  make(chan string),
+		done:   make(chan struct{}),
+		raw:    raw,
+	}
+
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		c.Error = s.err
+		return c
+	}
+	s.command = command
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.command = nil
+		s.mu.Unlock()
+		close(command.done)
+	}()
+
+	if !c.Urc {
+		line := c.Command
+
+		if c.LineEnd {
+			if c.Data != nil {
+				line += "\r"
+			} else {
+				line += "\r\n"
+			}
+		}
+
+		if err := s.write([]byte(line)); err != nil {
+			c.Error = err
+			return c
+		}
+	}
+
+	data := make([]string, 0)
+	dataSent := false
+	timeoutCh := time.After(time.Duration(c.Timeout) * time.Second)
+
+	for {
+		select {
+		case <-s.done:
+			c.Response = data
+			c.Error = s.err
+			return c
+		case <-timeoutCh:
+			c.Response = data
+			if c.ResponseChan == nil {
+				c.Error = errors.New("timeout")
+			}
+			return c
+		case line := <-command.lines:
+			data = append(data, line)
+			c.Response = data
+
+			if c.ResponseChan != nil {
+				c.ResponseChan <- line
+			}
+
+			// write the payload once the modem asks for it
+			if c.Data != nil && !dataSent && strings.HasPrefix(line, prompt) {
+				if err := s.write(c.Data); err != nil {
+					c.Error = err
+					return c
+				}
+				dataSent = true
+				continue
+			}
+
+			for _, faultStr := range c.Fault {
+				if strings.Contains(line, faultStr) {
+					c.Error = errors.New("faulty response detected")
+					return c
+				}
+			}
+
+			for _, desiredStr := range c.Desired {
+				if strings.Contains(line, desiredStr) {
+					return c
+				}
+			}
+
+			switch {
+			case line == "OK":
+				if c.Desired != nil && c.ResponseChan == nil {
+					c.Error = errors.New("desired response not found")
+				}
+				return c
+			case line == "ERROR":
+				c.Error = errors.New("modem error")
+				return c
+			case strings.HasPrefix(line, "+CME ERROR:"), strings.HasPrefix(line, "+CMS ERROR:"):
+				c.Error = errors.New(line)
+				return c
+			}
+		}
+	}
+}
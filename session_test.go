@@ -0,0 +1,68 @@
+package atcom
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionReleaseWhileAcquiring(t *testing.T) {
+	modem := newFakeModem()
+	at := NewAtcom(modem, nil)
+	attr := SerialAttr{Port: "/dev/ttyUSB2"}
+
+	var wg sync.WaitGroup
+
+	// every listener must end up on a live session, one that is being
+	// closed by the previous user never delivers its URC
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			prefix := fmt.Sprintf("+URC%d:", i)
+
+			for j := 0; j < 50; j++ {
+				received := make(chan string, 1)
+
+				stop, err := at.ListenURC(attr, prefix, func(line string) {
+					received <- line
+				})
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				modem.emit(fmt.Sprintf("\r\n%s %d\r\n", prefix, j))
+
+				select {
+				case <-received:
+				case <-time.After(time.Second):
+					t.Errorf("%s %d not received", prefix, j)
+				}
+
+				stop()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	assert.Nil(t, at.activeSession(attr.Port))
+}
+
+func TestCommandPrefix(t *testing.T) {
+	tests := map[string]string{
+		"AT+CMGR=3":    "+CMGR",
+		"AT+CREG?":     "+CREG",
+		"at+cops=?":    "+COPS",
+		"AT#SGACT=1,1": "#SGACT",
+		"ATI":          "",
+		"AT":           "",
+	}
+
+	for command, prefix := range tests {
+		assert.Equal(t, prefix, commandPrefix(command), command)
+	}
+}
@@ -0,0 +1,319 @@
+package atcom
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SIMState is the state reported by AT+CPIN?
+type SIMState string
+
+const (
+	SIMReady       SIMState = "READY"
+	SIMPIN         SIMState = "SIM PIN"
+	SIMPUK         SIMState = "SIM PUK"
+	SIMPIN2        SIMState = "SIM PIN2"
+	SIMPUK2        SIMState = "SIM PUK2"
+	SIMPhonePIN    SIMState = "PH-SIM PIN"
+	SIMNotInserted SIMState = "NOT INSERTED"
+	SIMBusy        SIMState = "BUSY"
+	SIMFailure     SIMState = "FAILURE"
+)
+
+// SIMRetries holds the remaining attempts of the SIM codes, -1 when unknown
+type SIMRetries struct {
+	PIN  int
+	PUK  int
+	PIN2 int
+	PUK2 int
+}
+
+// ErrLastAttempt is returned instead of using the last remaining attempt of
+// a code without confirmation, since a wrong code would block the SIM
+var ErrLastAttempt = errors.New("only one attempt left, confirmation required")
+
+// SIMState reads the SIM state with AT+CPIN?
+func (t *Atcom) SIMState(attr SerialAttr) (SIMState, error) {
+	com := NewATCommand("AT+CPIN?")
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+
+	if com.Error != nil {
+		// CME errors 10, 13 and 14 describe the SIM state
+		message := strings.ToUpper(com.Error.Error())
+
+		switch {
+		case strings.HasSuffix(message, ": 10"), strings.Contains(message, "NOT INSERTED"):
+			return SIMNotInserted, nil
+		case strings.HasSuffix(message, ": 13"), strings.Contains(message, "FAILURE"):
+			return SIMFailure, nil
+		case strings.HasSuffix(message, ": 14"), strings.Contains(message, "BUSY"):
+			return SIMBusy, nil
+		}
+		return "", com.Error
+	}
+
+	for _, line := range linesWithPrefix(com.Response, "+CPIN:") {
+		return SIMState(line), nil
+	}
+
+	return "", errors.New("no sim state in response")
+}
+
+// SIMRetries reads the remaining PIN and PUK attempts with the vendor
+// command of the modem, AT+CPINR otherwise
+func (t *Atcom) SIMRetries(attr SerialAttr) (SIMRetries, error) {
+	retries := SIMRetries{PIN: -1, PUK: -1, PIN2: -1, PUK2: -1}
+
+	vendor, err := t.vendor(attr)
+
+	if err != nil {
+		return retries, err
+	}
+
+	switch vendor {
+	case "Quectel":
+		com := NewATCommand("AT+QPINC?")
+		com.SerialAttr = attr
+		com = t.SendAT(com)
+
+		if com.Error != nil {
+			return retries, com.Error
+		}
+
+		// +QPINC: "SC",3,10
+		for _, line := range linesWithPrefix(com.Response, "+QPINC:") {
+			params := splitParams(line)
+
+			if len(params) < 3 {
+				continue
+			}
+
+			pin, _ := strconv.Atoi(params[1])
+			puk, _ := strconv.Atoi(params[2])
+
+			switch params[0] {
+			case "SC":
+				retries.PIN, retries.PUK = pin, puk
+			case "P2":
+				retries.PIN2, retries.PUK2 = pin, puk
+			}
+		}
+	case "Telit", "Thales/Cinterion":
+		// #PCT and ^SPIC report the attempts of the code currently required
+		command, prefix := "AT#PCT", "#PCT:"
+		if vendor != "Telit" {
+			command, prefix = "AT^SPIC", "^SPIC:"
+		}
+
+		state, err := t.SIMState(attr)
+
+		if err != nil {
+			return retries, err
+		}
+
+		com := NewATCommand(command)
+		com.SerialAttr = attr
+		com = t.SendAT(com)
+
+		if com.Error != nil {
+			return retries, com.Error
+		}
+
+		for _, line := range linesWithPrefix(com.Response, prefix) {
+			count, err := strconv.Atoi(splitParams(line)[0])
+
+			if err != nil {
+				return retries, fmt.Errorf("invalid response: %s", line)
+			}
+
+			switch state {
+			case SIMPUK:
+				retries.PUK = count
+			case SIMPIN2:
+				retries.PIN2 = count
+			case SIMPUK2:
+				retries.PUK2 = count
+			default:
+				retries.PIN = count
+			}
+		}
+	default:
+		com := NewATCommand("AT+CPINR")
+		com.SerialAttr = attr
+		com = t.SendAT(com)
+
+		if com.Error != nil {
+			return retries, com.Error
+		}
+
+		// +CPINR: SIM PIN,3,3
+		for _, line := range linesWithPrefix(com.Response, "+CPINR:") {
+			params := splitParams(line)
+
+			if len(params) < 2 {
+				continue
+			}
+
+			count, err := strconv.Atoi(params[1])
+
+			if err != nil {
+				continue
+			}
+
+			switch SIMState(params[0]) {
+			case SIMPIN:
+				retries.PIN = count
+			case SIMPUK:
+				retries.PUK = count
+			case SIMPIN2:
+				retries.PIN2 = count
+			case SIMPUK2:
+				retries.PUK2 = count
+			}
+		}
+	}
+
+	return retries, nil
+}
+
+// checkAttempts refuses to use the last attempt of a code unless confirmed.
+// Unknown counters do not block the operation.
+func (t *Atcom) checkAttempts(attr SerialAttr, puk bool, confirmLast bool) error {
+	if confirmLast {
+		return nil
+	}
+
+	retries, err := t.SIMRetries(attr)
+
+	if err != nil {
+		return err
+	}
+
+	remaining := retries.PIN
+	if puk {
+		remaining = retries.PUK
+	}
+
+	if remaining == 0 || remaining == 1 {
+		return ErrLastAttempt
+	}
+
+	return nil
+}
+
+// simCommand sends a command that uses up an attempt of the PIN or PUK
+func (t *Atcom) simCommand(attr SerialAttr, command string, puk bool, confirmLast bool) error {
+	if err := t.checkAttempts(attr, puk, confirmLast); err != nil {
+		return err
+	}
+
+	com := NewATCommand(command)
+	com.SerialAttr = attr
+	com.Timeout = 20
+	com = t.SendAT(com)
+	return com.Error
+}
+
+// validCode reports whether code consists of min to max digits
+func validCode(code string, min int, max int) bool {
+	if len(code) < min || len(code) > max {
+		return false
+	}
+
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkPIN returns an error unless every pin has 4 to 8 digits
+func checkPIN(pins ...string) error {
+	for _, pin := range pins {
+		if !validCode(pin, 4, 8) {
+			return errors.New("pin must have 4 to 8 digits")
+		}
+	}
+
+	return nil
+}
+
+// EnterPIN unlocks the SIM. The last remaining attempt is only used when
+// confirmLast is set.
+func (t *Atcom) EnterPIN(attr SerialAttr, pin string, confirmLast bool) error {
+	if err := checkPIN(pin); err != nil {
+		return err
+	}
+
+	return t.simCommand(attr, fmt.Sprintf("AT+CPIN=\"%s\"", pin), false, confirmLast)
+}
+
+// EnterPUK unblocks the SIM and sets a new PIN. The last remaining attempt
+// is only used when confirmLast is set.
+func (t *Atcom) EnterPUK(attr SerialAttr, puk string, newPIN string, confirmLast bool) error {
+	if !validCode(puk, 8, 8) {
+		return errors.New("puk must have 8 digits")
+	}
+
+	if err := checkPIN(newPIN); err != nil {
+		return err
+	}
+
+	// the attempts reported by #PCT and ^SPIC are those of the required code
+	state, err := t.SIMState(attr)
+
+	if err != nil {
+		return err
+	}
+
+	if state != SIMPUK {
+		return fmt.Errorf("sim does not require the puk: %s", state)
+	}
+
+	return t.simCommand(attr, fmt.Sprintf("AT+CPIN=\"%s\",\"%s\"", puk, newPIN), true, confirmLast)
+}
+
+// ChangePIN replaces the PIN of the SIM with AT+CPWD
+func (t *Atcom) ChangePIN(attr SerialAttr, oldPIN string, newPIN string, confirmLast bool) error {
+	if err := checkPIN(oldPIN, newPIN); err != nil {
+		return err
+	}
+
+	return t.simCommand(attr, fmt.Sprintf("AT+CPWD=\"SC\",\"%s\",\"%s\"", oldPIN, newPIN), false, confirmLast)
+}
+
+// SetPINLock enables or disables the PIN request at startup with AT+CLCK
+func (t *Atcom) SetPINLock(attr SerialAttr, enabled bool, pin string, confirmLast bool) error {
+	if err := checkPIN(pin); err != nil {
+		return err
+	}
+
+	mode := 0
+	if enabled {
+		mode = 1
+	}
+
+	return t.simCommand(attr, fmt.Sprintf("AT+CLCK=\"SC\",%d,\"%s\"", mode, pin), false, confirmLast)
+}
+
+// PINLockEnabled reports whether the SIM asks for the PIN at startup
+func (t *Atcom) PINLockEnabled(attr SerialAttr) (bool, error) {
+	com := NewATCommand("AT+CLCK=\"SC\",2")
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+
+	if com.Error != nil {
+		return false, com.Error
+	}
+
+	for _, line := range linesWithPrefix(com.Response, "+CLCK:") {
+		return splitParams(line)[0] == "1", nil
+	}
+
+	return false, errors.New("no lock state in response")
+}
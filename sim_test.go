@@ -0,0 +1,129 @@
+package atcom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSIMRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		vendor  string
+		state   string
+		command string
+		reply   string
+		retries SIMRetries
+	}{
+		{
+			name:    "quectel",
+			vendor:  "Quectel",
+			command: "AT+QPINC?",
+			reply:   "+QPINC: \"SC\",3,10\r\n+QPINC: \"P2\",2,9",
+			retries: SIMRetries{PIN: 3, PUK: 10, PIN2: 2, PUK2: 9},
+		},
+		{
+			name:    "telit pin",
+			vendor:  "Telit",
+			state:   "SIM PIN",
+			command: "AT#PCT",
+			reply:   "#PCT: 2",
+			retries: SIMRetries{PIN: 2, PUK: -1, PIN2: -1, PUK2: -1},
+		},
+		{
+			name:    "telit puk",
+			vendor:  "Telit",
+			state:   "SIM PUK",
+			command: "AT#PCT",
+			reply:   "#PCT: 7",
+			retries: SIMRetries{PIN: -1, PUK: 7, PIN2: -1, PUK2: -1},
+		},
+		{
+			name:    "thales puk2",
+			vendor:  "Cinterion",
+			state:   "SIM PUK2",
+			command: "AT^SPIC",
+			reply:   "^SPIC: 9",
+			retries: SIMRetries{PIN: -1, PUK: -1, PIN2: -1, PUK2: 9},
+		},
+		{
+			name:    "cpinr",
+			vendor:  "u-blox",
+			command: "AT+CPINR",
+			reply:   "+CPINR: SIM PIN,1,3\r\n+CPINR: SIM PUK,10,10\r\n+CPINR: SIM PIN2,3,3",
+			retries: SIMRetries{PIN: 1, PUK: 10, PIN2: 3, PUK2: -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modem := newFakeModem()
+			modem.on("AT+CGMI", "\r\n"+tt.vendor+"\r\n\r\nOK\r\n")
+			modem.on("AT+CPIN?", "\r\n+CPIN: "+tt.state+"\r\n\r\nOK\r\n")
+			modem.on(tt.command, "\r\n"+tt.reply+"\r\n\r\nOK\r\n")
+
+			at := NewAtcom(modem, nil)
+			retries, err := at.SIMRetries(SerialAttr{Port: "/dev/ttyUSB2"})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.retries, retries)
+		})
+	}
+}
+
+func TestEnterPINLastAttempt(t *testing.T) {
+	modem := newFakeModem()
+	modem.on("AT+CGMI", "\r\nQuectel\r\n\r\nOK\r\n")
+	modem.on("AT+QPINC?", "\r\n+QPINC: \"SC\",1,10\r\n\r\nOK\r\n")
+
+	at := NewAtcom(modem, nil)
+	attr := SerialAttr{Port: "/dev/ttyUSB2"}
+
+	assert.ErrorIs(t, at.EnterPIN(attr, "1234", false), ErrLastAttempt)
+	assert.NotContains(t, modem.commands(), `AT+CPIN="1234"`)
+
+	require.NoError(t, at.EnterPIN(attr, "1234", true))
+	assert.Contains(t, modem.commands(), `AT+CPIN="1234"`)
+}
+
+func TestEnterPUKLastAttempt(t *testing.T) {
+	modem := newFakeModem()
+	modem.on("AT+CGMI", "\r\nTelit\r\n\r\nOK\r\n")
+	modem.on("AT+CPIN?", "\r\n+CPIN: SIM PUK\r\n\r\nOK\r\n")
+	modem.on("AT#PCT", "\r\n#PCT: 1\r\n\r\nOK\r\n")
+
+	at := NewAtcom(modem, nil)
+	attr := SerialAttr{Port: "/dev/ttyUSB2"}
+
+	assert.ErrorIs(t, at.EnterPUK(attr, "12345678", "1234", false), ErrLastAttempt)
+	assert.Equal(t, 0, countCommand(modem.commands(), `AT+CPIN="12345678","1234"`))
+
+	modem.on("AT#PCT", "\r\n#PCT: 5\r\n\r\nOK\r\n")
+	require.NoError(t, at.EnterPUK(attr, "12345678", "1234", false))
+	assert.Equal(t, 1, countCommand(modem.commands(), `AT+CPIN="12345678","1234"`))
+
+	// the puk counter is unknown unless the sim asks for the puk
+	modem.on("AT+CPIN?", "\r\n+CPIN: SIM PIN\r\n\r\nOK\r\n")
+	assert.Error(t, at.EnterPUK(attr, "12345678", "1234", true))
+	assert.Equal(t, 1, countCommand(modem.commands(), `AT+CPIN="12345678","1234"`))
+}
+
+func TestSIMCodeValidation(t *testing.T) {
+	modem := newFakeModem()
+	at := NewAtcom(modem, nil)
+	attr := SerialAttr{Port: "/dev/ttyUSB2"}
+
+	for _, pin := range []string{"", "123", "123456789", "12a4", `12"4`} {
+		assert.Error(t, at.EnterPIN(attr, pin, true), pin)
+		assert.Error(t, at.EnterPUK(attr, "12345678", pin, true), pin)
+		assert.Error(t, at.ChangePIN(attr, "1234", pin, true), pin)
+		assert.Error(t, at.SetPINLock(attr, true, pin, true), pin)
+	}
+
+	for _, puk := range []string{"1234567", "123456789", "1234567a"} {
+		assert.Error(t, at.EnterPUK(attr, puk, "1234", true), puk)
+	}
+
+	assert.Empty(t, modem.commands())
+}
@@ -0,0 +1,164 @@
+package atcom
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SMSStatus is the <stat> of a stored message in PDU mode
+type SMSStatus int
+
+const (
+	SMSReceivedUnread SMSStatus = iota
+	SMSReceivedRead
+	SMSStoredUnsent
+	SMSStoredSent
+	SMSAll
+)
+
+// setPDUMode switches the modem to SMS PDU mode
+func (t *Atcom) setPDUMode(attr SerialAttr) error {
+	com := NewATCommand("AT+CMGF=0")
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+	return com.Error
+}
+
+// SendSMS sends text to number in PDU mode and returns the message
+// reference of each sent part
+func (t *Atcom) SendSMS(attr SerialAttr, number string, text string, opts SMSOptions) ([]int, error) {
+	pdus, err := EncodeSMS(number, text, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.setPDUMode(attr); err != nil {
+		return nil, err
+	}
+
+	refs := make([]int, 0, len(pdus))
+
+	for _, pdu := range pdus {
+		com := NewATCommand(fmt.Sprintf("AT+CMGS=%d", pdu.Length))
+		com.SerialAttr = attr
+		com.Data = []byte(pdu.Hex + "\x1a")
+		com.Timeout = 60
+		com = t.SendAT(com)
+
+		if com.Error != nil {
+			return refs, com.Error
+		}
+
+		for _, line := range linesWithPrefix(com.Response, "+CMGS:") {
+			if ref, err := strconv.Atoi(line); err == nil {
+				refs = append(refs, ref)
+			}
+		}
+	}
+
+	return refs, nil
+}
+
+// ReadSMS reads the message stored at index
+func (t *Atcom) ReadSMS(attr SerialAttr, index int) (SMS, error) {
+	if err := t.setPDUMode(attr); err != nil {
+		return SMS{}, err
+	}
+
+	com := NewATCommand(fmt.Sprintf("AT+CMGR=%d", index))
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+
+	if com.Error != nil {
+		return SMS{}, com.Error
+	}
+
+	messages, err := parseStoredSMS(com.Response, "+CMGR:", index)
+
+	if err != nil {
+		return SMS{}, err
+	}
+
+	if len(messages) == 0 {
+		return SMS{}, fmt.Errorf("no message at index %d", index)
+	}
+
+	return messages[0], nil
+}
+
+// ListSMS lists the stored messages with the given status. Concatenated
+// messages are returned part by part, see JoinSMS.
+func (t *Atcom) ListSMS(attr SerialAttr, status SMSStatus) ([]SMS, error) {
+	if err := t.setPDUMode(attr); err != nil {
+		return nil, err
+	}
+
+	com := NewATCommand(fmt.Sprintf("AT+CMGL=%d", status))
+	com.SerialAttr = attr
+	com.Timeout = 30
+	com = t.SendAT(com)
+
+	if com.Error != nil {
+		return nil, com.Error
+	}
+
+	return parseStoredSMS(com.Response, "+CMGL:", -1)
+}
+
+// DeleteSMS deletes the message stored at index
+func (t *Atcom) DeleteSMS(attr SerialAttr, index int) error {
+	com := NewATCommand(fmt.Sprintf("AT+CMGD=%d", index))
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+	return com.Error
+}
+
+// DeleteAllSMS deletes every message of the preferred storage
+func (t *Atcom) DeleteAllSMS(attr SerialAttr) error {
+	com := NewATCommand("AT+CMGD=1,4")
+	com.SerialAttr = attr
+	com.Timeout = 30
+	com = t.SendAT(com)
+	return com.Error
+}
+
+// parseStoredSMS decodes the header and PDU line pairs of +CMGR and +CMGL
+// responses. +CMGL headers start with the storage index, +CMGR headers do
+// not carry one and index is used instead.
+func parseStoredSMS(response []string, prefix string, index int) ([]SMS, error) {
+	messages := make([]SMS, 0)
+
+	for i := 0; i < len(response)-1; i++ {
+		if !strings.HasPrefix(response[i], prefix) {
+			continue
+		}
+
+		params := splitParams(strings.TrimPrefix(response[i], prefix))
+		sms, err := DecodeSMS(response[i+1])
+
+		if err != nil {
+			return nil, err
+		}
+
+		sms.Index = index
+
+		if prefix == "+CMGL:" {
+			if sms.Index, err = strconv.Atoi(params[0]); err != nil || len(params) < 2 {
+				return nil, errors.New("invalid response: " + response[i])
+			}
+			params = params[1:]
+		}
+
+		if status, err := strconv.Atoi(params[0]); err == nil {
+			sms.Status = SMSStatus(status)
+		}
+
+		messages = append(messages, sms)
+		i++
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,297 @@
+package atcom
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SMSHandler receives incoming messages and status reports. Returning nil
+// acknowledges the message: messages routed with +CMT and +CDS are
+// acknowledged with AT+CNMA when the modem expects it (AT+CSMS service 1),
+// stored messages are deleted when requested. Routed messages the handler
+// fails on are rejected with AT+CNMA=2.
+type SMSHandler func(sms SMS) error
+
+// SMSNotifyOptions configures SubscribeSMS
+type SMSNotifyOptions struct {
+	// Direct routes new messages to the host with +CMT instead of storing
+	// them and announcing them with +CMTI
+	Direct bool
+
+	// Delete removes stored messages once the handler acknowledged them
+	Delete bool
+
+	// OnError receives the errors of fetching or deleting messages
+	OnError func(err error)
+}
+
+type smsSubscription struct {
+	at      *Atcom
+	attr    SerialAttr
+	opts    SMSNotifyOptions
+	handler SMSHandler
+
+	// ack is set when routed messages must be acknowledged with AT+CNMA
+	ack bool
+
+	// parts of concatenated messages waiting for the rest
+	mu    sync.Mutex
+	parts []SMS
+}
+
+// SubscribeSMS enables new message indications (AT+CNMI) and calls handler
+// for every message announced with +CMTI, delivered with +CMT or reported
+// with +CDS. Concatenated messages are delivered once all parts arrived.
+func (t *Atcom) SubscribeSMS(attr SerialAttr, opts SMSNotifyOptions, handler SMSHandler) (stop func(), err error) {
+	sub := &smsSubscription{at: t, attr: attr, opts: opts, handler: handler}
+
+	stops := make([]func(), 0, 3)
+	stopAll := func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+
+	for _, urc := range []struct {
+		prefix string
+		extra  int
+		fn     func(lines []string)
+	}{
+		{"+CMTI:", 0, sub.onStored},
+		{"+CMT:", 1, sub.onDelivered},
+		{"+CDS:", 1, sub.onDelivered},
+	} {
+		stop, err := t.subscribe(attr, urc.prefix, urc.extra, urc.fn)
+
+		if err != nil {
+			stopAll()
+			return nil, err
+		}
+
+		stops = append(stops, stop)
+	}
+
+	if err := t.setPDUMode(attr); err != nil {
+		stopAll()
+		return nil, err
+	}
+
+	mode := 1
+	if opts.Direct {
+		mode = 2
+
+		ack, err := t.smsAckRequired(attr)
+
+		if err != nil {
+			stopAll()
+			return nil, err
+		}
+		sub.ack = ack
+	}
+
+	com := NewATCommand(fmt.Sprintf("AT+CNMI=2,%d,0,1,0", mode))
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+
+	if com.Error != nil {
+		stopAll()
+		return nil, com.Error
+	}
+
+	return stopAll, nil
+}
+
+// smsAckRequired reports whether routed messages need AT+CNMA, which is the
+// case for the phase 2+ messaging service selected with AT+CSMS=1
+func (t *Atcom) smsAckRequired(attr SerialAttr) (bool, error) {
+	com := NewATCommand("AT+CSMS?")
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+
+	if com.Error != nil {
+		return false, com.Error
+	}
+
+	for _, line := range linesWithPrefix(com.Response, "+CSMS:") {
+		if params := splitParams(line); len(params) > 0 {
+			return params[0] == "1", nil
+		}
+	}
+
+	return false, nil
+}
+
+// smsStorage returns the storage messages are read from (<mem1> of AT+CPMS)
+func (t *Atcom) smsStorage(attr SerialAttr) (string, error) {
+	com := NewATCommand("AT+CPMS?")
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+
+	if com.Error != nil {
+		return "", com.Error
+	}
+
+	for _, line := range linesWithPrefix(com.Response, "+CPMS:") {
+		if params := splitParams(line); len(params) > 0 {
+			return params[0], nil
+		}
+	}
+
+	return "", fmt.Errorf("no storage in response")
+}
+
+// setSMSStorage selects the storage messages are read from and deleted in
+func (t *Atcom) setSMSStorage(attr SerialAttr, storage string) error {
+	com := NewATCommand(fmt.Sprintf("AT+CPMS=\"%s\"", storage))
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+	return com.Error
+}
+
+func (sub *smsSubscription) fail(err error) {
+	if sub.opts.OnError != nil {
+		sub.opts.OnError(err)
+	}
+}
+
+// onStored fetches a message announced with +CMTI: "SM",3
+func (sub *smsSubscription) onStored(lines []string) {
+	params := splitParams(strings.TrimPrefix(lines[0], "+CMTI:"))
+
+	if len(params) < 2 {
+		sub.fail(fmt.Errorf("invalid indication: %s", lines[0]))
+		return
+	}
+
+	index, err := strconv.Atoi(params[1])
+
+	if err != nil {
+		sub.fail(fmt.Errorf("invalid indication: %s", lines[0]))
+		return
+	}
+
+	// read and delete from the storage the message was saved to, and
+	// switch back afterwards for later ReadSMS and ListSMS calls
+	if params[0] != "" {
+		previous, err := sub.at.smsStorage(sub.attr)
+
+		if err != nil {
+			sub.fail(err)
+			return
+		}
+
+		if previous != params[0] {
+			if err := sub.at.setSMSStorage(sub.attr, params[0]); err != nil {
+				sub.fail(err)
+				return
+			}
+
+			defer func() {
+				if err := sub.at.setSMSStorage(sub.attr, previous); err != nil {
+					sub.fail(err)
+				}
+			}()
+		}
+	}
+
+	sms, err := sub.at.ReadSMS(sub.attr, index)
+
+	if err != nil {
+		sub.fail(err)
+		return
+	}
+
+	sub.deliver(sms)
+}
+
+// onDelivered decodes a message routed to the host with +CMT or +CDS
+func (sub *smsSubscription) onDelivered(lines []string) {
+	if len(lines) < 2 {
+		return
+	}
+
+	sms, err := DecodeSMS(lines[1])
+
+	if err != nil {
+		sub.fail(err)
+		sub.acknowledge(false)
+		return
+	}
+
+	sub.acknowledge(sub.deliver(sms))
+}
+
+// acknowledge confirms the reception of a routed message with AT+CNMA or
+// rejects it with AT+CNMA=2, otherwise the modem waits for a timeout and
+// may stop routing messages
+func (sub *smsSubscription) acknowledge(accepted bool) {
+	if !sub.ack {
+		return
+	}
+
+	command := "AT+CNMA"
+	if !accepted {
+		command = "AT+CNMA=2"
+	}
+
+	com := NewATCommand(command)
+	com.SerialAttr = sub.attr
+	com = sub.at.SendAT(com)
+
+	if com.Error != nil {
+		sub.fail(com.Error)
+	}
+}
+
+// deliver passes complete messages to the handler and deletes the
+// acknowledged ones when requested. It reports whether the message was
+// accepted; parts of concatenated messages are accepted once buffered.
+func (sub *smsSubscription) deliver(sms SMS) bool {
+	if sms.ConcatTotal > 1 {
+		sub.mu.Lock()
+		sub.parts = append(sub.parts, sms)
+
+		group := make([]SMS, 0)
+		rest := make([]SMS, 0)
+
+		for _, part := range sub.parts {
+			if part.Address == sms.Address && part.ConcatRef == sms.ConcatRef && part.ConcatTotal == sms.ConcatTotal {
+				group = append(group, part)
+			} else {
+				rest = append(rest, part)
+			}
+		}
+
+		merged, complete := mergeParts(group)
+
+		if complete {
+			sub.parts = rest
+		}
+		sub.mu.Unlock()
+
+		if !complete {
+			return true
+		}
+		sms = merged
+	} else if sms.Index >= 0 {
+		sms.Indexes = []int{sms.Index}
+	}
+
+	if err := sub.handler(sms); err != nil {
+		return false
+	}
+
+	if !sub.opts.Delete {
+		return true
+	}
+
+	for _, index := range sms.Indexes {
+		if err := sub.at.DeleteSMS(sub.attr, index); err != nil {
+			sub.fail(err)
+		}
+	}
+
+	return true
+}
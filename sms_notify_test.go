@@ -0,0 +1,146 @@
+package atcom
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 27.005 SMS-DELIVER with the text hellohello
+const deliverPDU = "07917283010010F5040BC87238880900F10000993092516195800AE8329BFD4697D9EC37"
+
+func TestSubscribeSMSDirectAcknowledges(t *testing.T) {
+	modem := newFakeModem()
+	modem.on("AT+CSMS?", "\r\n+CSMS: 1,1,1,1\r\n\r\nOK\r\n")
+
+	at := NewAtcom(modem, nil)
+	attr := SerialAttr{Port: "/dev/ttyUSB2"}
+
+	received := make(chan SMS, 2)
+	var accept atomic.Bool
+	accept.Store(true)
+
+	stop, err := at.SubscribeSMS(attr, SMSNotifyOptions{Direct: true}, func(sms SMS) error {
+		received <- sms
+		if !accept.Load() {
+			return errors.New("rejected")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	defer stop()
+
+	assert.Contains(t, modem.commands(), "AT+CNMI=2,2,0,1,0")
+
+	modem.emit("\r\n+CMT: ,33\r\n" + deliverPDU + "\r\n")
+
+	select {
+	case sms := <-received:
+		assert.Equal(t, "hellohello", sms.Text)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message")
+	}
+
+	assert.Eventually(t, func() bool {
+		return countCommand(modem.commands(), "AT+CNMA") == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// rejected messages are negatively acknowledged
+	accept.Store(false)
+	modem.emit("\r\n+CMT: ,33\r\n" + deliverPDU + "\r\n")
+	<-received
+
+	assert.Eventually(t, func() bool {
+		return countCommand(modem.commands(), "AT+CNMA=2") == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, countCommand(modem.commands(), "AT+CNMA"))
+
+	// so are messages that can not be decoded
+	modem.emit("\r\n+CMT: ,1\r\n00\r\n")
+
+	assert.Eventually(t, func() bool {
+		return countCommand(modem.commands(), "AT+CNMA=2") == 2
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestSubscribeSMSDirectWithoutAcknowledgement(t *testing.T) {
+	modem := newFakeModem()
+	modem.on("AT+CSMS?", "\r\n+CSMS: 0,1,1,1\r\n\r\nOK\r\n")
+
+	at := NewAtcom(modem, nil)
+	received := make(chan SMS, 1)
+
+	stop, err := at.SubscribeSMS(SerialAttr{Port: "/dev/ttyUSB2"}, SMSNotifyOptions{Direct: true}, func(sms SMS) error {
+		received <- sms
+		return nil
+	})
+	require.NoError(t, err)
+	defer stop()
+
+	modem.emit("\r\n+CMT: ,33\r\n" + deliverPDU + "\r\n")
+	<-received
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, countCommand(modem.commands(), "AT+CNMA"))
+}
+
+func TestSubscribeSMSStoredRestoresStorage(t *testing.T) {
+	modem := newFakeModem()
+	modem.on("AT+CPMS?", "\r\n+CPMS: \"ME\",0,50,\"ME\",0,50,\"ME\",0,50\r\n\r\nOK\r\n")
+	modem.on("AT+CMGR=3", "\r\n+CMGR: 1,,33\r\n"+deliverPDU+"\r\n\r\nOK\r\n")
+
+	at := NewAtcom(modem, nil)
+	received := make(chan SMS, 1)
+
+	stop, err := at.SubscribeSMS(SerialAttr{Port: "/dev/ttyUSB2"}, SMSNotifyOptions{Delete: true}, func(sms SMS) error {
+		received <- sms
+		return nil
+	})
+	require.NoError(t, err)
+	defer stop()
+
+	modem.emit("\r\n+CMTI: \"SM\",3\r\n")
+
+	select {
+	case sms := <-received:
+		assert.Equal(t, "hellohello", sms.Text)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message")
+	}
+
+	assert.Eventually(t, func() bool {
+		return countCommand(modem.commands(), `AT+CPMS="ME"`) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// the message is read and deleted in its storage before switching back
+	commands := modem.commands()
+	switched := indexOf(commands, `AT+CPMS="SM"`)
+	restored := indexOf(commands, `AT+CPMS="ME"`)
+
+	require.True(t, switched >= 0)
+	assert.Less(t, switched, indexOf(commands, "AT+CMGR=3"))
+	assert.Less(t, indexOf(commands, "AT+CMGD=3"), restored)
+}
+
+func countCommand(commands []string, command string) int {
+	count := 0
+	for _, c := range commands {
+		if c == command {
+			count++
+		}
+	}
+	return count
+}
+
+func indexOf(commands []string, command string) int {
+	for i, c := range commands {
+		if c == command {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,720 @@
+package atcom
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf16"
+)
+
+// SMSEncoding is the alphabet used for the user data of a message
+type SMSEncoding int
+
+const (
+	// EncodingAuto picks GSM 7-bit when possible and UCS2 otherwise
+	EncodingAuto SMSEncoding = iota
+	EncodingGSM7
+	Encoding8Bit
+	EncodingUCS2
+)
+
+// SMSType is the TP-MTI of a message
+type SMSType int
+
+const (
+	SMSDeliver SMSType = iota
+	SMSSubmit
+	SMSStatusReport
+)
+
+// SMS is a decoded short message
+type SMS struct {
+	Type     SMSType
+	Index    int // storage index, -1 when the message is not stored
+	Status   SMSStatus
+	SMSC     string
+	Address  string // originator for SMS-DELIVER, destination for SMS-SUBMIT
+	Text     string
+	Data     []byte // raw user data of 8-bit messages
+	Encoding SMSEncoding
+	Time     time.Time // service centre time stamp
+
+	Reference int // TP-MR of SMS-SUBMIT and SMS-STATUS-REPORT
+
+	// Concatenation information, ConcatTotal is 0 for single messages
+	ConcatRef   int
+	ConcatTotal int
+	ConcatSeq   int
+
+	// Status report fields
+	Discharge    time.Time
+	ReportStatus int
+
+	// Indexes of all stored parts of a joined message
+	Indexes []int
+}
+
+// SMSOptions configures the SMS-SUBMIT PDUs created by EncodeSMS
+type SMSOptions struct {
+	Encoding     SMSEncoding
+	StatusReport bool
+	Validity     time.Duration // relative validity period, omitted when 0
+}
+
+// SMSPDU is an encoded SMS-SUBMIT with its TPDU length as used by AT+CMGS
+type SMSPDU struct {
+	Hex    string
+	Length int
+}
+
+// GSM 03.38 default alphabet, indexed by septet value
+var gsm7Alphabet = []rune("@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞ\x1bÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà")
+
+// GSM 03.38 extension table, reached through the 0x1B escape
+var gsm7Extension = map[byte]rune{
+	0x0A: '\f',
+	0x14: '^',
+	0x28: '{',
+	0x29: '}',
+	0x2F: '\\',
+	0x3C: '[',
+	0x3D: '~',
+	0x3E: ']',
+	0x40: '|',
+	0x65: '€',
+}
+
+const gsm7Escape = 0x1B
+
+var (
+	gsm7Reverse          = map[rune]byte{}
+	gsm7ExtensionReverse = map[rune]byte{}
+
+	// reference number of concatenated messages
+	concatRef uint32
+)
+
+func init() {
+	for i, r := range gsm7Alphabet {
+		if i != gsm7Escape {
+			gsm7Reverse[r] = byte(i)
+		}
+	}
+
+	for code, r := range gsm7Extension {
+		gsm7ExtensionReverse[r] = code
+	}
+}
+
+// gsm7Encode converts text to septets, ok is false when a character is not
+// part of the GSM 03.38 alphabet
+func gsm7Encode(text string) (septets []byte, ok bool) {
+	septets = make([]byte, 0, len(text))
+
+	for _, r := range text {
+		if code, found := gsm7Reverse[r]; found {
+			septets = append(septets, code)
+		} else if code, found := gsm7ExtensionReverse[r]; found {
+			septets = append(septets, gsm7Escape, code)
+		} else {
+			return nil, false
+		}
+	}
+
+	return septets, true
+}
+
+func gsm7Decode(septets []byte) string {
+	text := strings.Builder{}
+
+	for i := 0; i < len(septets); i++ {
+		code := septets[i] & 0x7F
+
+		if code == gsm7Escape && i+1 < len(septets) {
+			i++
+			if r, found := gsm7Extension[septets[i]&0x7F]; found {
+				text.WriteRune(r)
+			} else {
+				// unknown extensions fall back to the default alphabet
+				text.WriteRune(gsm7Alphabet[septets[i]&0x7F])
+			}
+			continue
+		}
+
+		if code == gsm7Escape {
+			text.WriteRune(' ')
+			continue
+		}
+
+		text.WriteRune(gsm7Alphabet[code])
+	}
+
+	return text.String()
+}
+
+// packSeptets packs septets into octets, starting after fill padding bits
+func packSeptets(septets []byte, fill int) []byte {
+	packed := make([]byte, (len(septets)*7+fill+7)/8)
+	bit := fill
+
+	for _, septet := range septets {
+		septet &= 0x7F
+		index := bit / 8
+		offset := bit % 8
+
+		packed[index] |= septet << offset
+		if offset > 1 {
+			packed[index+1] |= septet >> (8 - offset)
+		}
+		bit += 7
+	}
+
+	return packed
+}
+
+// unpackSeptets extracts count septets from packed octets after fill bits
+func unpackSeptets(packed []byte, fill int, count int) []byte {
+	septets := make([]byte, 0, count)
+
+	for i := 0; i < count; i++ {
+		bit := fill + i*7
+		index := bit / 8
+		offset := bit % 8
+
+		if index >= len(packed) {
+			break
+		}
+
+		septet := packed[index] >> offset
+		if offset > 1 && index+1 < len(packed) {
+			septet |= packed[index+1] << (8 - offset)
+		}
+		septets = append(septets, septet&0x7F)
+	}
+
+	return septets
+}
+
+func ucs2Encode(text string) []byte {
+	units := utf16.Encode([]rune(text))
+	encoded := make([]byte, 0, len(units)*2)
+
+	for _, unit := range units {
+		encoded = append(encoded, byte(unit>>8), byte(unit))
+	}
+
+	return encoded
+}
+
+func ucs2Decode(data []byte) string {
+	units := make([]uint16, 0, len(data)/2)
+
+	for i := 0; i+1 < len(data); i += 2 {
+		units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+	}
+
+	return string(utf16.Decode(units))
+}
+
+// smsAlphabet returns the encoding selected by an SMS data coding scheme
+func smsAlphabet(dcs byte) SMSEncoding {
+	switch {
+	case dcs&0x80 == 0x00:
+		// general data coding and automatic deletion groups
+		if dcs&0x20 != 0 {
+			// compressed text is not supported, expose it as data
+			return Encoding8Bit
+		}
+
+		switch (dcs >> 2) & 0x03 {
+		case 1:
+			return Encoding8Bit
+		case 2:
+			return EncodingUCS2
+		default:
+			return EncodingGSM7
+		}
+	case dcs&0xF0 == 0xF0:
+		if dcs&0x04 != 0 {
+			return Encoding8Bit
+		}
+		return EncodingGSM7
+	case dcs&0xF0 == 0xE0:
+		return EncodingUCS2
+	case dcs&0xF0 == 0xC0, dcs&0xF0 == 0xD0:
+		return EncodingGSM7
+	}
+
+	return Encoding8Bit
+}
+
+func encodeSemiOctets(digits string) []byte {
+	if len(digits)%2 != 0 {
+		digits += "F"
+	}
+
+	encoded := make([]byte, 0, len(digits)/2)
+
+	for i := 0; i < len(digits); i += 2 {
+		encoded = append(encoded, semiOctetValue(digits[i+1])<<4|semiOctetValue(digits[i]))
+	}
+
+	return encoded
+}
+
+func semiOctetValue(digit byte) byte {
+	switch {
+	case digit >= '0' && digit <= '9':
+		return digit - '0'
+	case digit == '*':
+		return 0x0A
+	case digit == '#':
+		return 0x0B
+	}
+
+	return 0x0F
+}
+
+func decodeSemiOctets(data []byte, count int) string {
+	const symbols = "0123456789*#abc"
+	digits := strings.Builder{}
+
+	for _, b := range data {
+		for _, nibble := range []byte{b & 0x0F, b >> 4} {
+			if digits.Len() < count && nibble != 0x0F {
+				digits.WriteByte(symbols[nibble])
+			}
+		}
+	}
+
+	return digits.String()
+}
+
+// encodeAddress encodes a phone number as TP-DA
+func encodeAddress(number string) ([]byte, error) {
+	toa := byte(0x81)
+
+	if strings.HasPrefix(number, "+") {
+		toa = 0x91
+		number = number[1:]
+	}
+
+	if number == "" {
+		return nil, errors.New("phone number is required")
+	}
+
+	for _, digit := range number {
+		if !strings.ContainsRune("0123456789*#", digit) {
+			return nil, fmt.Errorf("invalid phone number digit %q", digit)
+		}
+	}
+
+	address := []byte{byte(len(number)), toa}
+	return append(address, encodeSemiOctets(number)...), nil
+}
+
+// validityPeriod converts a duration to a relative TP-VP octet
+func validityPeriod(d time.Duration) byte {
+	minutes := int(d / time.Minute)
+
+	switch {
+	case minutes <= 12*60:
+		return byte(max(minutes/5-1, 0))
+	case minutes <= 24*60:
+		return byte(143 + (minutes-12*60)/30)
+	case minutes <= 30*24*60:
+		return byte(166 + minutes/(24*60))
+	default:
+		return byte(min(192+minutes/(7*24*60), 255))
+	}
+}
+
+// EncodeSMS builds the SMS-SUBMIT PDUs for text. Text that does not fit a
+// single message is split into concatenated parts.
+func EncodeSMS(number string, text string, opts SMSOptions) ([]SMSPDU, error) {
+	address, err := encodeAddress(number)
+
+	if err != nil {
+		return nil, err
+	}
+
+	encoding := opts.Encoding
+	septets, gsm7 := gsm7Encode(text)
+
+	switch {
+	case encoding == EncodingAuto && gsm7:
+		encoding = EncodingGSM7
+	case encoding == EncodingAuto:
+		encoding = EncodingUCS2
+	case encoding == EncodingGSM7 && !gsm7:
+		return nil, errors.New("text can not be encoded with the GSM 7-bit alphabet")
+	}
+
+	// split user data into chunks of septets or octets
+	var chunks [][]byte
+	var dcs byte
+
+	switch encoding {
+	case EncodingGSM7:
+		dcs = 0x00
+		chunks = splitUserData(septets, 160, 153, func(chunk []byte, end int) int {
+			// never separate an escape from its extension character
+			if chunk[end-1] == gsm7Escape {
+				return end - 1
+			}
+			return end
+		})
+	case Encoding8Bit:
+		dcs = 0x04
+		chunks = splitUserData([]byte(text), 140, 134, nil)
+	case EncodingUCS2:
+		dcs = 0x08
+		chunks = splitUserData(ucs2Encode(text), 140, 134, func(chunk []byte, end int) int {
+			// keep code units and surrogate pairs in the same part
+			end -= end % 2
+			if unit := uint16(chunk[end-2])<<8 | uint16(chunk[end-1]); utf16.IsSurrogate(rune(unit)) && unit < 0xDC00 {
+				return end - 2
+			}
+			return end
+		})
+	default:
+		return nil, errors.New("unknown sms encoding")
+	}
+
+	ref := byte(atomic.AddUint32(&concatRef, 1))
+	pdus := make([]SMSPDU, 0, len(chunks))
+
+	for i, chunk := range chunks {
+		firstOctet := byte(0x01)
+
+		if opts.Validity > 0 {
+			firstOctet |= 0x10
+		}
+
+		if opts.StatusReport {
+			firstOctet |= 0x20
+		}
+
+		var udh []byte
+		if len(chunks) > 1 {
+			firstOctet |= 0x40
+			udh = []byte{0x05, 0x00, 0x03, ref, byte(len(chunks)), byte(i + 1)}
+		}
+
+		tpdu := []byte{firstOctet, 0x00}
+		tpdu = append(tpdu, address...)
+		tpdu = append(tpdu, 0x00, dcs)
+
+		if opts.Validity > 0 {
+			tpdu = append(tpdu, validityPeriod(opts.Validity))
+		}
+
+		if encoding == EncodingGSM7 {
+			fill := (7 - (len(udh)*8)%7) % 7
+			tpdu = append(tpdu, byte((len(udh)*8+fill)/7+len(chunk)))
+			tpdu = append(tpdu, udh...)
+			tpdu = append(tpdu, packSeptets(chunk, fill)...)
+		} else {
+			tpdu = append(tpdu, byte(len(udh)+len(chunk)))
+			tpdu = append(tpdu, udh...)
+			tpdu = append(tpdu, chunk...)
+		}
+
+		pdus = append(pdus, SMSPDU{
+			Hex:    "00" + strings.ToUpper(hex.EncodeToString(tpdu)),
+			Length: len(tpdu),
+		})
+	}
+
+	return pdus, nil
+}
+
+// splitUserData splits data into a single chunk of up to single units or
+// into parts of up to part units. adjust may shorten a part boundary.
+func splitUserData(data []byte, single int, part int, adjust func(chunk []byte, end int) int) [][]byte {
+	if len(data) <= single {
+		return [][]byte{data}
+	}
+
+	chunks := make([][]byte, 0)
+
+	for len(data) > 0 {
+		end := min(part, len(data))
+
+		if adjust != nil && end < len(data) {
+			end = adjust(data, end)
+		}
+
+		chunks = append(chunks, data[:end])
+		data = data[end:]
+	}
+
+	return chunks
+}
+
+type pduReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *pduReader) bytes(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+
+	if r.pos+n > len(r.data) {
+		r.err = errors.New("pdu is too short")
+		return make([]byte, n)
+	}
+
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *pduReader) byte() byte {
+	return r.bytes(1)[0]
+}
+
+func (r *pduReader) address() string {
+	length := int(r.byte())
+	toa := r.byte()
+	data := r.bytes((length + 1) / 2)
+
+	if (toa>>4)&0x07 == 0x05 {
+		// alphanumeric sender
+		return gsm7Decode(unpackSeptets(data, 0, length*4/7))
+	}
+
+	number := decodeSemiOctets(data, length)
+	if (toa>>4)&0x07 == 0x01 {
+		number = "+" + number
+	}
+
+	return number
+}
+
+func (r *pduReader) timestamp() time.Time {
+	data := r.bytes(7)
+	fields := make([]int, 6)
+
+	for i := range fields {
+		fields[i] = int(data[i]&0x0F)*10 + int(data[i]>>4)
+	}
+
+	quarters := int(data[6]&0x07)*10 + int(data[6]>>4)
+	if data[6]&0x08 != 0 {
+		quarters = -quarters
+	}
+
+	zone := time.FixedZone("", quarters*15*60)
+	return time.Date(2000+fields[0], time.Month(fields[1]), fields[2], fields[3], fields[4], fields[5], 0, zone)
+}
+
+// DecodeSMS decodes a PDU as returned by AT+CMGR, AT+CMGL or +CMT, including
+// the leading SMSC address
+func DecodeSMS(pdu string) (SMS, error) {
+	data, err := hex.DecodeString(strings.TrimSpace(pdu))
+
+	if err != nil {
+		return SMS{}, err
+	}
+
+	r := &pduReader{data: data}
+	sms := SMS{Index: -1}
+
+	if length := int(r.byte()); length > 0 {
+		toa := r.byte()
+		sms.SMSC = decodeSemiOctets(r.bytes(length-1), (length-1)*2)
+		if (toa>>4)&0x07 == 0x01 {
+			sms.SMSC = "+" + sms.SMSC
+		}
+	}
+
+	firstOctet := r.byte()
+	var dcs byte
+
+	switch firstOctet & 0x03 {
+	case 0x00:
+		sms.Type = SMSDeliver
+		sms.Address = r.address()
+		r.byte() // TP-PID
+		dcs = r.byte()
+		sms.Time = r.timestamp()
+	case 0x01:
+		sms.Type = SMSSubmit
+		sms.Reference = int(r.byte())
+		sms.Address = r.address()
+		r.byte() // TP-PID
+		dcs = r.byte()
+
+		switch (firstOctet >> 3) & 0x03 {
+		case 0x02:
+			r.byte()
+		case 0x01, 0x03:
+			r.bytes(7)
+		}
+	case 0x02:
+		sms.Type = SMSStatusReport
+		sms.Reference = int(r.byte())
+		sms.Address = r.address()
+		sms.Time = r.timestamp()
+		sms.Discharge = r.timestamp()
+		sms.ReportStatus = int(r.byte())
+		return sms, r.err
+	default:
+		return sms, errors.New("unsupported message type")
+	}
+
+	length := int(r.byte())
+
+	if r.err != nil {
+		return sms, r.err
+	}
+
+	ud := r.data[r.pos:]
+	headerLength := 0
+
+	if firstOctet&0x40 != 0 && len(ud) > 0 {
+		headerLength = int(ud[0]) + 1
+
+		if headerLength > len(ud) {
+			return sms, errors.New("invalid user data header")
+		}
+
+		sms.parseUDH(ud[1:headerLength])
+	}
+
+	sms.Encoding = smsAlphabet(dcs)
+
+	// TP-UDL counts octets, including the header, except for GSM 7 bit
+	if sms.Encoding != EncodingGSM7 && headerLength > length {
+		return sms, errors.New("user data header exceeds user data length")
+	}
+
+	switch sms.Encoding {
+	case EncodingGSM7:
+		fill := (7 - (headerLength*8)%7) % 7
+		count := length - (headerLength*8+fill)/7
+
+		if count < 0 {
+			return sms, errors.New("user data header exceeds user data length")
+		}
+
+		sms.Text = gsm7Decode(unpackSeptets(ud[headerLength:], fill, count))
+	case EncodingUCS2:
+		sms.Text = ucs2Decode(ud[headerLength:min(length, len(ud))])
+	default:
+		sms.Data = ud[headerLength:min(length, len(ud))]
+		sms.Text = string(sms.Data)
+	}
+
+	return sms, nil
+}
+
+// parseUDH reads the concatenation information elements of a user data header
+func (sms *SMS) parseUDH(udh []byte) {
+	for i := 0; i+1 < len(udh); {
+		iei := udh[i]
+		length := int(udh[i+1])
+		data := udh[i+2 : min(i+2+length, len(udh))]
+
+		switch {
+		case iei == 0x00 && len(data) == 3:
+			sms.ConcatRef = int(data[0])
+			sms.ConcatTotal = int(data[1])
+			sms.ConcatSeq = int(data[2])
+		case iei == 0x08 && len(data) == 4:
+			sms.ConcatRef = int(data[0])<<8 | int(data[1])
+			sms.ConcatTotal = int(data[2])
+			sms.ConcatSeq = int(data[3])
+		}
+
+		i += 2 + length
+	}
+}
+
+// JoinSMS merges the parts of concatenated messages. Parts of messages that
+// are not complete yet are returned unchanged.
+func JoinSMS(messages []SMS) []SMS {
+	type key struct {
+		address string
+		ref     int
+		total   int
+	}
+
+	groups := make(map[key][]SMS)
+	joined := make([]SMS, 0, len(messages))
+
+	for _, sms := range messages {
+		if sms.ConcatTotal > 1 {
+			k := key{sms.Address, sms.ConcatRef, sms.ConcatTotal}
+			groups[k] = append(groups[k], sms)
+		}
+	}
+
+	done := make(map[key]bool)
+
+	for _, sms := range messages {
+		if sms.ConcatTotal <= 1 {
+			joined = append(joined, sms)
+			continue
+		}
+
+		k := key{sms.Address, sms.ConcatRef, sms.ConcatTotal}
+
+		if done[k] {
+			continue
+		}
+		done[k] = true
+
+		if merged, ok := mergeParts(groups[k]); ok {
+			joined = append(joined, merged)
+		} else {
+			joined = append(joined, groups[k]...)
+		}
+	}
+
+	return joined
+}
+
+// mergeParts joins the parts of one concatenated message when all of them are present
+func mergeParts(parts []SMS) (SMS, bool) {
+	ordered := make([]*SMS, parts[0].ConcatTotal)
+
+	for i := range parts {
+		seq := parts[i].ConcatSeq
+		if seq < 1 || seq > len(ordered) {
+			return SMS{}, false
+		}
+		ordered[seq-1] = &parts[i]
+	}
+
+	for _, part := range ordered {
+		if part == nil {
+			return SMS{}, false
+		}
+	}
+
+	merged := *ordered[0]
+	merged.Text = ""
+	merged.Data = nil
+	merged.Indexes = nil
+
+	for _, part := range ordered {
+		merged.Text += part.Text
+		if part.Data != nil {
+			merged.Data = append(merged.Data, part.Data...)
+		}
+		if part.Index >= 0 {
+			merged.Indexes = append(merged.Indexes, part.Index)
+		}
+	}
+
+	return merged, true
+}
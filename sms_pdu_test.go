@@ -0,0 +1,178 @@
+package atcom
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeSMS(t *testing.T) {
+	tests := []struct {
+		name     string
+		pdu      string
+		smsc     string
+		address  string
+		text     string
+		encoding SMSEncoding
+		time     string // formatted as 01-02 15:04:05 -0700, empty to skip
+	}{
+		{
+			name:     "27.005 deliver",
+			pdu:      "07917283010010F5040BC87238880900F10000993092516195800AE8329BFD4697D9EC37",
+			smsc:     "+27381000015",
+			address:  "27838890001",
+			text:     "hellohello",
+			encoding: EncodingGSM7,
+			time:     "03-29 15:16:59 +0200",
+		},
+		{
+			name:     "alphanumeric originator",
+			pdu:      "07919471060040340409D0C6A733390400004250925153700004D4F29C0E",
+			smsc:     "+491760000443",
+			address:  "FONIC",
+			text:     "Test",
+			encoding: EncodingGSM7,
+		},
+		{
+			name:     "ucs2",
+			pdu:      "0791947106004034040C9194710600403400084250925153700004040020AC",
+			smsc:     "+491760000443",
+			address:  "+491760000443",
+			text:     "Ѐ€",
+			encoding: EncodingUCS2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sms, err := DecodeSMS(tt.pdu)
+			require.NoError(t, err)
+
+			assert.Equal(t, SMSDeliver, sms.Type)
+			assert.Equal(t, tt.smsc, sms.SMSC)
+			assert.Equal(t, tt.address, sms.Address)
+			assert.Equal(t, tt.text, sms.Text)
+			assert.Equal(t, tt.encoding, sms.Encoding)
+			assert.Equal(t, -1, sms.Index)
+
+			if tt.time != "" {
+				assert.Equal(t, tt.time, sms.Time.Format("01-02 15:04:05 -0700"))
+			}
+		})
+	}
+}
+
+func TestDecodeSMSErrors(t *testing.T) {
+	for name, pdu := range map[string]string{
+		"not hex":           "zz",
+		"truncated address": "07917283010010F5040BC872",
+		"truncated smsc":    "0791728301001",
+		// UDHI set with a 6 octet header but TP-UDL 0
+		"gsm7 header beyond length":  "07917283010010F5440BC87238880900F10000993092516195800005000301020141",
+		"ucs2 header beyond length":  "07917283010010F5440BC87238880900F10008993092516195800205000301020100410042",
+		"8-bit header beyond length": "07917283010010F5440BC87238880900F10004993092516195800305000301020141",
+		"header beyond user data":    "07917283010010F5440BC87238880900F10000993092516195800A0A0003010201",
+	} {
+		assert.NotPanics(t, func() {
+			_, err := DecodeSMS(pdu)
+			assert.Error(t, err, name)
+		}, name)
+	}
+}
+
+func TestEncodeSMS(t *testing.T) {
+	// 27.005 example, SMS-SUBMIT with relative validity of 4 days
+	pdus, err := EncodeSMS("+46708251358", "hellohello", SMSOptions{Validity: 96 * time.Hour})
+	require.NoError(t, err)
+
+	assert.Equal(t, []SMSPDU{{Hex: "0011000B916407281553F80000AA0AE8329BFD4697D9EC37", Length: 23}}, pdus)
+}
+
+func TestEncodeSMSRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		opts     SMSOptions
+		parts    int
+		encoding SMSEncoding
+	}{
+		{"gsm7", "hello @£$", SMSOptions{}, 1, EncodingGSM7},
+		{"gsm7 extension", "{[~|^]} \\ 10€", SMSOptions{}, 1, EncodingGSM7},
+		{"gsm7 160 septets", strings.Repeat("a", 160), SMSOptions{}, 1, EncodingGSM7},
+		{"gsm7 extension counts twice", strings.Repeat("€", 80), SMSOptions{}, 1, EncodingGSM7},
+		{"gsm7 concatenated", strings.Repeat("0123456789", 20), SMSOptions{}, 2, EncodingGSM7},
+		{"escape not split", strings.Repeat("a", 152) + "€" + strings.Repeat("b", 10), SMSOptions{}, 2, EncodingGSM7},
+		{"ucs2", "Привет", SMSOptions{}, 1, EncodingUCS2},
+		{"ucs2 concatenated", strings.Repeat("Ж", 100), SMSOptions{}, 2, EncodingUCS2},
+		{"surrogate not split", strings.Repeat("Ж", 66) + "😀" + strings.Repeat("Ж", 4), SMSOptions{}, 2, EncodingUCS2},
+		{"8-bit", "binary", SMSOptions{Encoding: Encoding8Bit}, 1, Encoding8Bit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdus, err := EncodeSMS("+491760000443", tt.text, tt.opts)
+			require.NoError(t, err)
+			require.Len(t, pdus, tt.parts)
+
+			messages := make([]SMS, 0, len(pdus))
+
+			for _, pdu := range pdus {
+				assert.Equal(t, len(pdu.Hex)/2-1, pdu.Length)
+
+				sms, err := DecodeSMS(pdu.Hex)
+				require.NoError(t, err)
+
+				assert.Equal(t, SMSSubmit, sms.Type)
+				assert.Equal(t, "+491760000443", sms.Address)
+				assert.Equal(t, tt.encoding, sms.Encoding)
+				messages = append(messages, sms)
+			}
+
+			// parts end on a character boundary
+			if tt.parts > 1 {
+				assert.NotContains(t, messages[0].Text, "�")
+				assert.NotEqual(t, " ", messages[0].Text[len(messages[0].Text)-1:])
+			}
+
+			joined := JoinSMS(messages)
+			require.Len(t, joined, 1)
+			assert.Equal(t, tt.text, joined[0].Text)
+		})
+	}
+}
+
+func TestEncodeSMSErrors(t *testing.T) {
+	_, err := EncodeSMS("", "text", SMSOptions{})
+	assert.Error(t, err)
+
+	_, err = EncodeSMS("+49abc", "text", SMSOptions{})
+	assert.Error(t, err)
+
+	_, err = EncodeSMS("+491760000443", "Привет", SMSOptions{Encoding: EncodingGSM7})
+	assert.Error(t, err)
+}
+
+func TestJoinSMS(t *testing.T) {
+	part := func(index int, ref int, seq int, text string) SMS {
+		return SMS{Index: index, Address: "+49", ConcatRef: ref, ConcatTotal: 2, ConcatSeq: seq, Text: text}
+	}
+
+	messages := []SMS{
+		part(3, 7, 2, "world"),
+		{Index: 1, Address: "+49", Text: "single"},
+		part(2, 7, 1, "hello "),
+		part(5, 8, 1, "incomplete"),
+	}
+
+	joined := JoinSMS(messages)
+	require.Len(t, joined, 3)
+
+	assert.Equal(t, "hello world", joined[0].Text)
+	assert.Equal(t, []int{2, 3}, joined[0].Indexes)
+	assert.Equal(t, "single", joined[1].Text)
+	assert.Equal(t, "incomplete", joined[2].Text)
+	assert.Equal(t, 1, joined[2].ConcatSeq)
+}
@@ -0,0 +1,234 @@
+package atcom
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// socketDriver implements the socket commands of a vendor
+type socketDriver interface {
+	// receive reads the data buffered by the modem, empty when there is none
+	receive(id int) ([]byte, error)
+	// send writes data, timeout is given in seconds
+	send(id int, data []byte, timeout int) error
+	// close closes the connection on the modem
+	close(id int) error
+}
+
+// socketAddr is the address of a connection handled by the modem
+type socketAddr struct {
+	network string
+	address string
+}
+
+func (a socketAddr) Network() string { return a.network }
+func (a socketAddr) String() string  { return a.address }
+
+// socketConn is a net.Conn over the socket commands of a modem. Incoming
+// data is announced by URCs and read on demand in Read.
+type socketConn struct {
+	driver socketDriver
+	id     int
+	local  net.Addr
+	remote net.Addr
+
+	// release frees the resources of the connection after close
+	release func()
+
+	mu            sync.Mutex
+	buf           []byte
+	pending       bool // the modem announced data that was not read yet
+	remoteClosed  bool
+	closed        bool
+	readDeadline  time.Time
+	writeDeadline time.Time
+	wake          chan struct{}
+}
+
+// maxSocketSend is the largest chunk written by a single send command
+const maxSocketSend = 1460
+
+func newSocketConn(driver socketDriver, id int, local net.Addr, remote net.Addr, release func()) *socketConn {
+	return &socketConn{
+		driver:  driver,
+		id:      id,
+		local:   local,
+		remote:  remote,
+		release: release,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// signal wakes up a blocked Read
+func (c *socketConn) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dataAvailable is called when the modem announces incoming data
+func (c *socketConn) dataAvailable() {
+	c.mu.Lock()
+	c.pending = true
+	c.mu.Unlock()
+	c.signal()
+}
+
+// closedByPeer is called when the modem reports the connection as closed
+func (c *socketConn) closedByPeer() {
+	c.mu.Lock()
+	c.remoteClosed = true
+	c.mu.Unlock()
+	c.signal()
+}
+
+func (c *socketConn) Read(b []byte) (int, error) {
+	for {
+		c.mu.Lock()
+
+		if c.closed {
+			c.mu.Unlock()
+			return 0, net.ErrClosed
+		}
+
+		if len(c.buf) > 0 {
+			n := copy(b, c.buf)
+			c.buf = c.buf[n:]
+			c.mu.Unlock()
+			return n, nil
+		}
+
+		pending := c.pending
+		remoteClosed := c.remoteClosed
+		deadline := c.readDeadline
+		c.pending = false
+		c.mu.Unlock()
+
+		// fetch announced data, and what is left after the peer closed
+		if pending || remoteClosed {
+			data, err := c.driver.receive(c.id)
+
+			if err != nil && !remoteClosed {
+				return 0, err
+			}
+
+			if len(data) > 0 {
+				c.mu.Lock()
+				c.buf = append(c.buf, data...)
+				c.pending = true
+				c.mu.Unlock()
+				continue
+			}
+		}
+
+		if remoteClosed {
+			return 0, io.EOF
+		}
+
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+
+		if deadline.IsZero() {
+			<-c.wake
+			continue
+		}
+
+		timer := time.NewTimer(time.Until(deadline))
+
+		select {
+		case <-c.wake:
+		case <-timer.C:
+		}
+
+		timer.Stop()
+	}
+}
+
+func (c *socketConn) Write(b []byte) (int, error) {
+	written := 0
+
+	for written < len(b) {
+		c.mu.Lock()
+		closed := c.closed || c.remoteClosed
+		deadline := c.writeDeadline
+		c.mu.Unlock()
+
+		if closed {
+			return written, net.ErrClosed
+		}
+
+		timeout := 30
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+
+			if remaining <= 0 {
+				return written, os.ErrDeadlineExceeded
+			}
+			timeout = max(int(remaining/time.Second), 1)
+		}
+
+		chunk := b[written:min(written+maxSocketSend, len(b))]
+
+		if err := c.driver.send(c.id, chunk, timeout); err != nil {
+			return written, err
+		}
+
+		written += len(chunk)
+	}
+
+	return written, nil
+}
+
+func (c *socketConn) Close() error {
+	c.mu.Lock()
+
+	if c.closed {
+		c.mu.Unlock()
+		return net.ErrClosed
+	}
+
+	c.closed = true
+	c.mu.Unlock()
+	c.signal()
+
+	err := c.driver.close(c.id)
+	c.release()
+	return err
+}
+
+func (c *socketConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *socketConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *socketConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.writeDeadline = t
+	c.mu.Unlock()
+	c.signal()
+	return nil
+}
+
+func (c *socketConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	c.signal()
+	return nil
+}
+
+func (c *socketConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.writeDeadline = t
+	c.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,144 @@
+package atcom
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// usbDevice is a USB device found in sysfs
+type usbDevice struct {
+	vid     string
+	pid     string
+	vendor  string
+	product string
+	serial  string
+	path    string // sysfs path relative to the root, e.g. /devices/platform/scb/usb1/1-1
+}
+
+// SetSysfsRoot changes the directory sysfs is read from, /sys by default.
+// Detection falls back to udevadm and lsusb when it is not readable.
+func (t *Atcom) SetSysfsRoot(root string) {
+	t.sysfsRoot = root
+}
+
+// sysfs joins elem to the sysfs root
+func (t *Atcom) sysfs(elem ...string) string {
+	root := t.sysfsRoot
+	if root == "" {
+		root = "/sys"
+	}
+
+	return filepath.Join(append([]string{root}, elem...)...)
+}
+
+// relativeSysfs strips the sysfs root from a resolved path
+func (t *Atcom) relativeSysfs(path string) string {
+	root, err := filepath.EvalSymlinks(t.sysfs())
+
+	if err != nil {
+		root = t.sysfs()
+	}
+
+	return strings.TrimPrefix(path, root)
+}
+
+// readUSBDevice reads the attributes of the USB device at dir
+func (t *Atcom) readUSBDevice(dir string) usbDevice {
+	return usbDevice{
+		vid:     readSysfs(filepath.Join(dir, "idVendor")),
+		pid:     readSysfs(filepath.Join(dir, "idProduct")),
+		vendor:  readSysfs(filepath.Join(dir, "manufacturer")),
+		product: readSysfs(filepath.Join(dir, "product")),
+		serial:  readSysfs(filepath.Join(dir, "serial")),
+		path:    t.relativeSysfs(dir),
+	}
+}
+
+// usbDevices lists the USB devices in /sys/bus/usb/devices
+func (t *Atcom) usbDevices() ([]usbDevice, error) {
+	entries, err := os.ReadDir(t.sysfs("bus", "usb", "devices"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([]usbDevice, 0)
+
+	for _, entry := range entries {
+		// interfaces are named <device>:<configuration>.<interface>
+		if strings.Contains(entry.Name(), ":") {
+			continue
+		}
+
+		dir, err := filepath.EvalSymlinks(t.sysfs("bus", "usb", "devices", entry.Name()))
+
+		if err != nil {
+			continue
+		}
+
+		devices = append(devices, t.readUSBDevice(dir))
+	}
+
+	return devices, nil
+}
+
+// sysfsPorts lists the USB serial ports in /sys/class/tty with the same
+// details as udevadm, see getAvailablePorts
+func (t *Atcom) sysfsPorts() ([]map[string]string, error) {
+	entries, err := os.ReadDir(t.sysfs("class", "tty"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	ports := make([]map[string]string, 0)
+
+	for _, entry := range entries {
+		dir, err := filepath.EvalSymlinks(t.sysfs("class", "tty", entry.Name()))
+
+		if err != nil {
+			continue
+		}
+
+		// the interface of a USB tty is one of its parents
+		iface := filepath.Dir(dir)
+		for readSysfs(filepath.Join(iface, "bInterfaceNumber")) == "" && iface != filepath.Dir(iface) {
+			iface = filepath.Dir(iface)
+		}
+
+		number := readSysfs(filepath.Join(iface, "bInterfaceNumber"))
+
+		if number == "" {
+			continue
+		}
+
+		device := t.readUSBDevice(filepath.Dir(iface))
+
+		ports = append(ports, map[string]string{
+			"port":             "/dev/" + entry.Name(),
+			"vendor":           device.vendor,
+			"vendor_id":        device.vid,
+			"model":            device.product,
+			"product_id":       device.pid,
+			"interface":        "if" + number,
+			"ID_USB_VENDOR_ID": device.vid,
+			"ID_USB_MODEL_ID":  device.pid,
+			"serial":           device.serial,
+			"path":             device.path,
+		})
+	}
+
+	return ports, nil
+}
+
+// readSysfs returns the trimmed content of a sysfs attribute, empty on errors
+func readSysfs(path string) string {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
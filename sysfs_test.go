@@ -0,0 +1,132 @@
+package atcom
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sixfab/atcomv2/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSysfs builds a sysfs tree with a Quectel EG25-G behind a root hub
+// and returns its root
+func fakeSysfs(t *testing.T) string {
+	root := t.TempDir()
+
+	write := func(path string, content string) {
+		path = filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content+"\n"), 0o644))
+	}
+	link := func(path string, target string) {
+		path = filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.Symlink(filepath.Join(root, target), path))
+	}
+
+	hub := "devices/platform/scb/usb1"
+	modem := hub + "/1-1"
+
+	write(hub+"/idVendor", "1d6b")
+	write(hub+"/idProduct", "0002")
+
+	write(modem+"/idVendor", "2c7c")
+	write(modem+"/idProduct", "0125")
+	write(modem+"/manufacturer", "Quectel")
+	write(modem+"/product", "EG25-G")
+	write(modem+"/serial", "0123456789")
+
+	for _, iface := range []struct{ name, number, tty string }{
+		{"1-1:1.2", "02", "ttyUSB2"},
+		{"1-1:1.3", "03", "ttyUSB3"},
+	} {
+		write(modem+"/"+iface.name+"/bInterfaceNumber", iface.number)
+		write(modem+"/"+iface.name+"/"+iface.tty+"/tty/"+iface.tty+"/dev", "188:2")
+		link("class/tty/"+iface.tty, modem+"/"+iface.name+"/"+iface.tty+"/tty/"+iface.tty)
+		link("bus/usb/devices/"+iface.name, modem+"/"+iface.name)
+	}
+
+	// CDC ACM ports are a direct child of the interface
+	write(modem+"/1-1:1.0/bInterfaceNumber", "00")
+	write(modem+"/1-1:1.0/tty/ttyACM0/dev", "166:0")
+	link("class/tty/ttyACM0", modem+"/1-1:1.0/tty/ttyACM0")
+
+	write("devices/virtual/tty/ttyS0/dev", "4:64")
+	link("class/tty/ttyS0", "devices/virtual/tty/ttyS0")
+
+	link("bus/usb/devices/usb1", hub)
+	link("bus/usb/devices/1-1", modem)
+
+	return root
+}
+
+func TestUSBDevices(t *testing.T) {
+	at := NewAtcom(nil, nil)
+	at.SetSysfsRoot(fakeSysfs(t))
+
+	devices, err := at.usbDevices()
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []usbDevice{
+		{vid: "2c7c", pid: "0125", vendor: "Quectel", product: "EG25-G", serial: "0123456789", path: "/devices/platform/scb/usb1/1-1"},
+		{vid: "1d6b", pid: "0002", path: "/devices/platform/scb/usb1"},
+	}, devices)
+}
+
+func TestSysfsPorts(t *testing.T) {
+	at := NewAtcom(nil, nil)
+	at.SetSysfsRoot(fakeSysfs(t))
+
+	ports, err := at.sysfsPorts()
+	require.NoError(t, err)
+	require.Len(t, ports, 3)
+
+	interfaces := make(map[string]string)
+	for _, port := range ports {
+		interfaces[port["port"]] = port["interface"]
+
+		assert.Equal(t, "2c7c", port["vendor_id"])
+		assert.Equal(t, "0125", port["product_id"])
+		assert.Equal(t, "EG25-G", port["model"])
+		assert.Equal(t, "0123456789", port["serial"])
+		assert.Equal(t, "/devices/platform/scb/usb1/1-1", port["path"])
+	}
+
+	assert.Equal(t, map[string]string{
+		"/dev/ttyACM0": "if00",
+		"/dev/ttyUSB2": "if02",
+		"/dev/ttyUSB3": "if03",
+	}, interfaces)
+}
+
+func TestSysfsFallback(t *testing.T) {
+	shell := &mocks.MockShell{}
+	shell.On("Command", "bash", []string{"-c", "/usr/bin/find /sys/bus/usb/devices/usb*/ -name dev"}).
+		Return("/sys/bus/usb/devices/usb1/1-1/1-1:1.2/ttyUSB2/tty/ttyUSB2/dev\n", nil)
+	shell.On("Command", "bash", []string{"-c", "udevadm info -q property --export -p /sys/bus/usb/devices/usb1/1-1/1-1:1.2/ttyUSB2/tty/ttyUSB2"}).
+		Return("DEVPATH='/devices/pci0000:00/0000:00:14.0/usb1/1-1/1-1:1.2/ttyUSB2/tty/ttyUSB2'\n"+
+			"DEVNAME='/dev/ttyUSB2'\nID_VENDOR_ID='2c7c'\nID_MODEL_ID='0125'\nID_USB_INTERFACE_NUM='02'\n", nil)
+	shell.On("Command", "lsusb", mock.Anything).
+		Return("Bus 001 Device 002: ID 2c7c:0125 Quectel Wireless Solutions Co., Ltd. EC25 LTE modem\n", nil)
+
+	at := NewAtcom(nil, shell)
+	at.SetSysfsRoot(filepath.Join(t.TempDir(), "missing"))
+
+	ports, err := at.getAvailablePorts()
+	require.NoError(t, err)
+	require.Len(t, ports, 1)
+
+	assert.Equal(t, "/dev/ttyUSB2", ports[0]["port"])
+	assert.Equal(t, "if02", ports[0]["interface"])
+	assert.Equal(t, "/devices/pci0000:00/0000:00:14.0/usb1/1-1", ports[0]["path"])
+
+	modem, err := at.findModem(SupportedModems())
+	require.NoError(t, err)
+
+	assert.Equal(t, "2c7c", modem.VID)
+	assert.Equal(t, "0125", modem.PID)
+	shell.AssertExpectations(t)
+}
@@ -0,0 +1,22 @@
+package atcom
+
+// Telit gives access to the features specific to Telit modules
+type Telit struct {
+	// ContextID is the PDP context used by the socket commands
+	ContextID int
+
+	at   *Atcom
+	attr SerialAttr
+}
+
+// Telit returns the Telit specific API for the modem on attr
+func (t *Atcom) Telit(attr SerialAttr) *Telit {
+	return &Telit{ContextID: 1, at: t, attr: attr}
+}
+
+// command creates a command for the port of the modem
+func (m *Telit) command(command string) *ATCommand {
+	com := NewATCommand(command)
+	com.SerialAttr = m.attr
+	return com
+}
@@ -0,0 +1,214 @@
+package atcom
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// telitSockets drives the Telit socket commands in command mode with
+// received data in hex
+type telitSockets struct {
+	m *Telit
+	s *session
+}
+
+func (d *telitSockets) receive(id int) ([]byte, error) {
+	// #SI: <connId>,<sent>,<received>,<buff_in>,<ack_waiting>
+	com := d.m.command(fmt.Sprintf("AT#SI=%d", id))
+	com = d.s.sendAT(com)
+
+	if com.Error != nil {
+		return nil, com.Error
+	}
+
+	buffered := 0
+	for _, line := range linesWithPrefix(com.Response, "#SI:") {
+		if params := splitParams(line); len(params) > 3 {
+			buffered, _ = strconv.Atoi(params[3])
+		}
+	}
+
+	if buffered == 0 {
+		return nil, nil
+	}
+
+	com = d.m.command(fmt.Sprintf("AT#SRECV=%d,%d", id, min(buffered, 1500)))
+	com = d.s.sendAT(com)
+
+	if com.Error != nil {
+		return nil, com.Error
+	}
+
+	// #SRECV: <connId>,<len> is followed by the data in hex
+	for i, line := range com.Response {
+		if strings.HasPrefix(line, "#SRECV:") && i+1 < len(com.Response) {
+			return hex.DecodeString(com.Response[i+1])
+		}
+	}
+
+	return nil, nil
+}
+
+func (d *telitSockets) send(id int, data []byte, timeout int) error {
+	com := d.m.command(fmt.Sprintf("AT#SSENDEXT=%d,%d", id, len(data)))
+	com.Data = data
+	com.Timeout = timeout
+	com = d.s.sendAT(com)
+	return com.Error
+}
+
+func (d *telitSockets) close(id int) error {
+	com := d.m.command(fmt.Sprintf("AT#SH=%d", id))
+	com.Timeout = 15
+	com = d.s.sendAT(com)
+	return com.Error
+}
+
+// activateContext activates the PDP context of the sockets if needed
+func (m *Telit) activateContext() error {
+	com := m.command("AT#SGACT?")
+	com = m.at.SendAT(com)
+
+	if com.Error != nil {
+		return com.Error
+	}
+
+	for _, line := range linesWithPrefix(com.Response, "#SGACT:") {
+		params := splitParams(line)
+
+		if len(params) > 1 && params[0] == strconv.Itoa(m.ContextID) && params[1] == "1" {
+			return nil
+		}
+	}
+
+	com = m.command(fmt.Sprintf("AT#SGACT=%d,1", m.ContextID))
+	com.Timeout = 150
+	com = m.at.SendAT(com)
+	return com.Error
+}
+
+// Dial connects to address with the socket commands of the modem. Supported
+// networks are "tcp", "tcp4", "udp" and "udp4". Up to six connections can
+// be open at the same time. Dial enables AT#NCIH=1, which stays enabled for
+// the whole modem, to learn which connection was closed.
+func (m *Telit) Dial(network string, address string) (net.Conn, error) {
+	return m.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to address like Dial, giving up when ctx is done
+func (m *Telit) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	protocol, ok := map[string]int{"tcp": 0, "tcp4": 0, "udp": 1, "udp4": 1}[network]
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported network %s", network)
+	}
+
+	host, portStr, err := net.SplitHostPort(address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := strconv.Atoi(portStr)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %s", portStr)
+	}
+
+	s, err := m.at.acquireSession(m.attr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := s.allocID("connection", 1, 6)
+
+	if err != nil {
+		m.at.releaseSession(s)
+		return nil, err
+	}
+
+	stops := make([]func(), 0, 2)
+	release := func() {
+		for _, stop := range stops {
+			stop()
+		}
+		s.freeID("connection", id)
+		m.at.releaseSession(s)
+	}
+
+	driver := &telitSockets{m: m, s: s}
+	conn := newSocketConn(driver, id, socketAddr{network, ""}, socketAddr{network, address}, release)
+
+	for prefix, fn := range map[string]func(line string){
+		// SRING: <connId>
+		"SRING:": func(line string) {
+			if splitParams(strings.TrimPrefix(line, "SRING:"))[0] == strconv.Itoa(id) {
+				conn.dataAvailable()
+			}
+		},
+		// NO CARRIER: <connId>,<cause>
+		"NO CARRIER:": func(line string) {
+			if splitParams(strings.TrimPrefix(line, "NO CARRIER:"))[0] == strconv.Itoa(id) {
+				conn.closedByPeer()
+			}
+		},
+	} {
+		stop, err := m.at.ListenURC(m.attr, prefix, fn)
+
+		if err != nil {
+			release()
+			return nil, err
+		}
+
+		stops = append(stops, stop)
+	}
+
+	commands := []string{
+		// report closed sockets with NO CARRIER: <connId>
+		"AT#NCIH=1",
+		fmt.Sprintf("AT#SCFG=%d,%d,300,90,600,50", id, m.ContextID),
+		// SRING without data, received data in hex
+		fmt.Sprintf("AT#SCFGEXT=%d,0,1,0", id),
+	}
+
+	for _, command := range commands {
+		com := m.command(command)
+		com = m.at.SendAT(com)
+
+		if com.Error != nil {
+			release()
+			return nil, com.Error
+		}
+	}
+
+	if err := m.activateContext(); err != nil {
+		release()
+		return nil, err
+	}
+
+	// the command returns once the connection is established
+	com := m.command(fmt.Sprintf("AT#SD=%d,%d,%d,\"%s\",0,0,1", id, protocol, port, host))
+	com.Timeout = 65
+	// a refused connection ends with a bare NO CARRIER instead of an error
+	com.Fault = []string{"NO CARRIER"}
+	com = m.at.sendATContext(ctx, com)
+
+	if com.Error != nil {
+		err := com.Error
+		if len(com.Response) > 0 && com.Response[len(com.Response)-1] == "NO CARRIER" {
+			err = errors.New("connection failed: NO CARRIER")
+		}
+
+		driver.close(id)
+		release()
+		return nil, err
+	}
+
+	return conn, nil
+}
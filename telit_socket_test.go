@@ -0,0 +1,100 @@
+package atcom
+
+import (
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTelitSocketModem returns a Telit modem with an active context
+func newTelitSocketModem() *fakeModem {
+	modem := newFakeModem()
+	modem.on("AT#SGACT?", "\r\n#SGACT: 1,1\r\n\r\nOK\r\n")
+	modem.on("AT#SI=", "\r\n#SI: 1,0,0,0,0\r\n\r\nOK\r\n")
+	modem.on("AT#SSENDEXT=", "\r\n> ")
+
+	return modem
+}
+
+func TestTelitDial(t *testing.T) {
+	modem := newTelitSocketModem()
+
+	at := NewAtcom(modem, nil)
+	conn, err := at.Telit(SerialAttr{Port: "/dev/ttyUSB2"}).Dial("tcp", "example.com:80")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"AT#NCIH=1",
+		"AT#SCFG=1,1,300,90,600,50",
+		"AT#SCFGEXT=1,0,1,0",
+		"AT#SGACT?",
+		`AT#SD=1,0,80,"example.com",0,0,1`,
+	}, modem.commands())
+
+	require.NoError(t, conn.Close())
+	assert.Contains(t, modem.commands(), "AT#SH=1")
+}
+
+func TestTelitDialRefused(t *testing.T) {
+	modem := newTelitSocketModem()
+	modem.on("AT#SD=", "\r\nNO CARRIER\r\n")
+
+	at := NewAtcom(modem, nil)
+
+	start := time.Now()
+	_, err := at.Telit(SerialAttr{Port: "/dev/ttyUSB2"}).Dial("udp", "192.0.2.1:5000")
+	require.Error(t, err)
+
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Contains(t, err.Error(), "NO CARRIER")
+	assert.Contains(t, modem.commands(), `AT#SD=1,1,5000,"192.0.2.1",0,0,1`)
+	assert.Contains(t, modem.commands(), "AT#SH=1")
+}
+
+func TestTelitSocketReadWrite(t *testing.T) {
+	modem := newTelitSocketModem()
+	modem.on("hello", "\r\nOK\r\n")
+
+	at := NewAtcom(modem, nil)
+	conn, err := at.Telit(SerialAttr{Port: "/dev/ttyUSB2"}).Dial("tcp", "example.com:80")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	n, err := conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Contains(t, modem.commands(), "AT#SSENDEXT=1,5")
+	assert.Contains(t, modem.commands(), "hello")
+
+	// SRING announces data, read in hex after #SI reported its size
+	var polls atomic.Int32
+	modem.onFunc("AT#SI=1", func(string) string {
+		if polls.Add(1) == 1 {
+			return "\r\n#SI: 1,5,8,8,0\r\n\r\nOK\r\n"
+		}
+		return "\r\n#SI: 1,5,8,0,0\r\n\r\nOK\r\n"
+	})
+	modem.on("AT#SRECV=1,8", "\r\n#SRECV: 1,8\r\n61620D0A4F4B0D0A\r\n\r\nOK\r\n")
+	modem.emit("\r\nSRING: 1\r\n")
+
+	buf := make([]byte, 64)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	n, err = conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ab\r\nOK\r\n", string(buf[:n]))
+
+	// closed connections are reported with their id
+	modem.emit("\r\nNO CARRIER: 2,0\r\n")
+	modem.emit("\r\nNO CARRIER: 1,0\r\n")
+
+	_, err = conn.Read(buf)
+	assert.ErrorIs(t, err, io.EOF)
+
+	_, err = conn.Write([]byte("hello"))
+	assert.ErrorIs(t, err, net.ErrClosed)
+}
@@ -0,0 +1,263 @@
+package atcom
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TestValue is a single entry of a test command response. It is either a
+// literal value, a numeric range such as 0-4 or a nested group as used by
+// +CIND: ("battchg",(0-5)).
+type TestValue struct {
+	Value  string
+	Quoted bool
+	Range  bool
+	Min    int
+	Max    int
+	Group  []TestValue
+}
+
+// TestParam holds the values supported for one parameter of a command
+type TestParam struct {
+	Values []TestValue
+}
+
+// TestResult is one parsed line of a test command (AT+XXX=?) response
+type TestResult struct {
+	Prefix string
+	Params []TestParam
+}
+
+func (v TestValue) String() string {
+	switch {
+	case v.Group != nil:
+		parts := make([]string, 0, len(v.Group))
+		for _, value := range v.Group {
+			parts = append(parts, value.String())
+		}
+		return "(" + strings.Join(parts, ",") + ")"
+	case v.Range:
+		return fmt.Sprintf("%d-%d", v.Min, v.Max)
+	case v.Quoted:
+		return strconv.Quote(v.Value)
+	default:
+		return v.Value
+	}
+}
+
+// Empty reports whether the parameter was omitted in the response
+func (p TestParam) Empty() bool {
+	return len(p.Values) == 0
+}
+
+// Allows reports whether value is one of the supported values of the parameter
+func (p TestParam) Allows(value string) bool {
+	number, err := strconv.Atoi(value)
+
+	for _, v := range p.Values {
+		if v.Range {
+			if err == nil && number >= v.Min && number <= v.Max {
+				return true
+			}
+			continue
+		}
+
+		if v.Value == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Ints expands the numeric values and ranges of the parameter
+func (p TestParam) Ints() []int {
+	ints := make([]int, 0)
+
+	for _, v := range p.Values {
+		if v.Range {
+			for i := v.Min; i <= v.Max; i++ {
+				ints = append(ints, i)
+			}
+			continue
+		}
+
+		if number, err := strconv.Atoi(v.Value); err == nil && !v.Quoted {
+			ints = append(ints, number)
+		}
+	}
+
+	return ints
+}
+
+// ParseTestResult parses a test command response line like
+// +CFUN: (0,1,4),(0,1) into its parameters
+func ParseTestResult(line string) (TestResult, error) {
+	result := TestResult{}
+	line = strings.TrimSpace(line)
+
+	// responses like ATS0=? come without a prefix
+	if index := strings.Index(line, ":"); index > 0 && !strings.ContainsAny(line[:index], "(\"") {
+		result.Prefix = line[:index]
+		line = strings.TrimSpace(line[index+1:])
+	}
+
+	parser := &testParser{s: line}
+	items, err := parser.parseSequence(0)
+
+	if err != nil {
+		return result, err
+	}
+
+	for _, item := range items {
+		switch {
+		case item == nil:
+			result.Params = append(result.Params, TestParam{})
+		case item.Group != nil:
+			result.Params = append(result.Params, TestParam{Values: item.Group})
+		default:
+			result.Params = append(result.Params, TestParam{Values: []TestValue{*item}})
+		}
+	}
+
+	return result, nil
+}
+
+type testParser struct {
+	s   string
+	pos int
+}
+
+func (p *testParser) skipSpaces() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// parseSequence parses comma separated items until end, or until the end of
+// input when end is 0. Omitted items are returned as nil.
+func (p *testParser) parseSequence(end byte) ([]*TestValue, error) {
+	items := make([]*TestValue, 0)
+
+	for {
+		p.skipSpaces()
+
+		item, err := p.parseItem()
+
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+		p.skipSpaces()
+
+		if p.pos >= len(p.s) {
+			if end != 0 {
+				return nil, fmt.Errorf("missing %q in %q", end, p.s)
+			}
+			return items, nil
+		}
+
+		switch c := p.s[p.pos]; {
+		case c == ',':
+			p.pos++
+		case end != 0 && c == end:
+			p.pos++
+			return items, nil
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d in %q", c, p.pos, p.s)
+		}
+	}
+}
+
+func (p *testParser) parseItem() (*TestValue, error) {
+	if p.pos >= len(p.s) {
+		return nil, nil
+	}
+
+	switch p.s[p.pos] {
+	case ',', ')':
+		return nil, nil
+	case '(':
+		p.pos++
+		items, err := p.parseSequence(')')
+
+		if err != nil {
+			return nil, err
+		}
+
+		group := make([]TestValue, 0, len(items))
+		for _, item := range items {
+			if item != nil {
+				group = append(group, *item)
+			}
+		}
+		return &TestValue{Group: group}, nil
+	case '"':
+		end := strings.IndexByte(p.s[p.pos+1:], '"')
+
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string in %q", p.s)
+		}
+
+		value := p.s[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return &TestValue{Value: value, Quoted: true}, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] != ',' && p.s[p.pos] != ')' {
+		p.pos++
+	}
+
+	value := strings.TrimSpace(p.s[start:p.pos])
+
+	if bounds := strings.SplitN(value, "-", 2); len(bounds) == 2 {
+		min, errMin := strconv.Atoi(bounds[0])
+		max, errMax := strconv.Atoi(bounds[1])
+
+		if errMin == nil && errMax == nil {
+			return &TestValue{Value: value, Range: true, Min: min, Max: max}, nil
+		}
+	}
+
+	return &TestValue{Value: value}, nil
+}
+
+// TestCommand runs the test form (AT+XXX=?) of the given command and returns
+// the supported values of each parameter
+func (t *Atcom) TestCommand(attr SerialAttr, command string) ([]TestResult, error) {
+	command = strings.TrimSuffix(strings.TrimSpace(command), "=?")
+
+	if !strings.HasPrefix(strings.ToUpper(command), "AT") {
+		command = "AT" + command
+	}
+
+	com := NewATCommand(command + "=?")
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+
+	if com.Error != nil {
+		return nil, com.Error
+	}
+
+	prefix := strings.ToUpper(command[2:])
+	results := make([]TestResult, 0)
+
+	for _, line := range com.Response {
+		if !strings.HasPrefix(line, "(") && !strings.HasPrefix(line, prefix+":") {
+			continue
+		}
+
+		result, err := ParseTestResult(line)
+
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,92 @@
+package atcom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTestResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		prefix string
+		params []string // String of every parameter's values, "" when omitted
+	}{
+		{
+			name:   "ranges and lists",
+			line:   "+CFUN: (0,1,4),(0-1)",
+			prefix: "+CFUN",
+			params: []string{"0 1 4", "0-1"},
+		},
+		{
+			name:   "nested groups",
+			line:   `+CIND: ("battchg",(0-5)),("signal",(0-5))`,
+			prefix: "+CIND",
+			params: []string{`"battchg" (0-5)`, `"signal" (0-5)`},
+		},
+		{
+			name:   "without prefix",
+			line:   "(0-255)",
+			params: []string{"0-255"},
+		},
+		{
+			name:   "COPS with empty group",
+			line:   `+COPS: (2,"Telekom.de","TDG","26201",7),(1,"","","26202",2),,(0-4),(0-2)`,
+			prefix: "+COPS",
+			params: []string{`2 "Telekom.de" "TDG" "26201" 7`, `1 "" "" "26202" 2`, "", "0-4", "0-2"},
+		},
+		{
+			name:   "COPS without operators",
+			line:   "+COPS: (),,(0-4),(0-2)",
+			prefix: "+COPS",
+			params: []string{"", "", "0-4", "0-2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseTestResult(tt.line)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.prefix, result.Prefix)
+			require.Len(t, result.Params, len(tt.params))
+
+			for i, param := range result.Params {
+				values := ""
+				for j, value := range param.Values {
+					if j > 0 {
+						values += " "
+					}
+					values += value.String()
+				}
+
+				assert.Equal(t, tt.params[i], values, "param %d", i)
+				assert.Equal(t, tt.params[i] == "", param.Empty(), "param %d", i)
+			}
+		})
+	}
+}
+
+func TestParseTestResultErrors(t *testing.T) {
+	for _, line := range []string{
+		"+CFUN: (0,1",
+		`+COPS: ("unterminated`,
+		"+CFUN: (0,1)x",
+	} {
+		_, err := ParseTestResult(line)
+		assert.Error(t, err, line)
+	}
+}
+
+func TestTestParam(t *testing.T) {
+	result, err := ParseTestResult(`+CNMI: (0-2),(0,1,"x")`)
+	require.NoError(t, err)
+
+	assert.True(t, result.Params[0].Allows("2"))
+	assert.False(t, result.Params[0].Allows("3"))
+	assert.True(t, result.Params[1].Allows("x"))
+	assert.Equal(t, []int{0, 1, 2}, result.Params[0].Ints())
+	assert.Equal(t, []int{0, 1}, result.Params[1].Ints())
+}
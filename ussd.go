@@ -0,0 +1,291 @@
+package atcom
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// USSDStatus is the <m> of a +CUSD result code
+type USSDStatus int
+
+const (
+	USSDDone           USSDStatus = 0 // no further user action required
+	USSDActionRequired USSDStatus = 1 // network expects a reply, e.g. a menu
+	USSDTerminated     USSDStatus = 2 // dialogue terminated by the network
+	USSDOtherClient    USSDStatus = 3 // other local client has responded
+	USSDNotSupported   USSDStatus = 4
+	USSDTimedOut       USSDStatus = 5 // network time out
+)
+
+// USSDResponse is a decoded +CUSD result code
+type USSDResponse struct {
+	Status USSDStatus
+	Text   string
+	DCS    int
+}
+
+// USSDSession is a USSD dialogue. The port stays open until the dialogue
+// ends or Cancel is called.
+type USSDSession struct {
+	// Timeout is the time to wait for a network answer
+	Timeout time.Duration
+
+	at        *Atcom
+	attr      SerialAttr
+	charset   string
+	responses chan USSDResponse
+	stop      func()
+	active    bool
+}
+
+// SendUSSD sends a single USSD request like *100# and returns the network
+// answer. Dialogues asking for a reply are cancelled, see StartUSSD.
+func (t *Atcom) SendUSSD(attr SerialAttr, code string) (USSDResponse, error) {
+	ussd, response, err := t.StartUSSD(attr, code)
+
+	if err != nil {
+		return response, err
+	}
+
+	return response, ussd.Cancel()
+}
+
+// StartUSSD starts a USSD dialogue with code and returns its first answer.
+// Menus are continued with Reply and left with Cancel.
+func (t *Atcom) StartUSSD(attr SerialAttr, code string) (*USSDSession, USSDResponse, error) {
+	ussd := &USSDSession{
+		Timeout:   30 * time.Second,
+		at:        t,
+		attr:      attr,
+		responses: make(chan USSDResponse, 4),
+	}
+
+	charset, err := t.characterSet(attr)
+
+	if err != nil {
+		return nil, USSDResponse{}, err
+	}
+
+	ussd.charset = charset
+	stop, err := t.ListenURC(attr, "+CUSD:", ussd.onResult)
+
+	if err != nil {
+		return nil, USSDResponse{}, err
+	}
+
+	ussd.stop = stop
+	response, err := ussd.send(code)
+
+	if err != nil {
+		ussd.stop()
+		return nil, response, err
+	}
+
+	return ussd, response, nil
+}
+
+// Reply answers a menu of the network, e.g. with the selected item number
+func (u *USSDSession) Reply(text string) (USSDResponse, error) {
+	if !u.active {
+		return USSDResponse{}, errors.New("ussd session is not active")
+	}
+
+	return u.send(text)
+}
+
+// Cancel ends the dialogue and releases the port
+func (u *USSDSession) Cancel() error {
+	var err error
+
+	if u.active {
+		com := NewATCommand("AT+CUSD=2")
+		com.SerialAttr = u.attr
+		com = u.at.SendAT(com)
+		err = com.Error
+		u.active = false
+	}
+
+	u.stop()
+	return err
+}
+
+// send transmits text within the dialogue and waits for the +CUSD answer
+func (u *USSDSession) send(text string) (USSDResponse, error) {
+	encoded, err := encodeUSSD(text, u.charset)
+
+	if err != nil {
+		return USSDResponse{}, err
+	}
+
+	// a late answer to an earlier request must not answer this one
+	for len(u.responses) > 0 {
+		<-u.responses
+	}
+
+	com := NewATCommand(fmt.Sprintf("AT+CUSD=1,\"%s\",15", encoded))
+	com.SerialAttr = u.attr
+	com = u.at.SendAT(com)
+
+	if com.Error != nil {
+		u.active = false
+		return USSDResponse{}, com.Error
+	}
+
+	// some modems answer before the final OK
+	for _, line := range com.Response {
+		if strings.HasPrefix(line, "+CUSD:") {
+			u.onResult(line)
+		}
+	}
+
+	select {
+	case response := <-u.responses:
+		u.active = response.Status == USSDActionRequired
+
+		if response.Status == USSDNotSupported || response.Status == USSDTimedOut {
+			return response, fmt.Errorf("ussd request failed with status %d", response.Status)
+		}
+		return response, nil
+	case <-time.After(u.Timeout):
+		u.active = false
+		return USSDResponse{}, errors.New("timeout")
+	}
+}
+
+func (u *USSDSession) onResult(line string) {
+	response, err := ParseUSSD(line, u.charset)
+
+	if err != nil {
+		return
+	}
+
+	select {
+	case u.responses <- response:
+	default:
+	}
+}
+
+// ParseUSSD decodes a +CUSD: <m>[,<str>,<dcs>] result code. charset is the
+// character set selected with AT+CSCS, e.g. "GSM", "IRA", "UCS2" or "HEX".
+func ParseUSSD(line string, charset string) (USSDResponse, error) {
+	params := splitParams(strings.TrimPrefix(line, "+CUSD:"))
+	response := USSDResponse{DCS: 15}
+
+	status, err := strconv.Atoi(params[0])
+
+	if err != nil {
+		return response, errors.New("invalid ussd response: " + line)
+	}
+
+	response.Status = USSDStatus(status)
+
+	if len(params) > 2 {
+		if dcs, err := strconv.Atoi(params[2]); err == nil {
+			response.DCS = dcs
+		}
+	}
+
+	if len(params) > 1 {
+		response.Text = decodeUSSD(params[1], byte(response.DCS), strings.ToUpper(charset))
+	}
+
+	return response, nil
+}
+
+// cbsAlphabet returns the encoding selected by a cell broadcast data coding
+// scheme (3GPP TS 23.038) as used by USSD, and the number of leading
+// characters (GSM 7-bit) or octets (UCS2) holding a language indication
+func cbsAlphabet(dcs byte) (SMSEncoding, int) {
+	switch {
+	case dcs == 0x10:
+		return EncodingGSM7, 3
+	case dcs == 0x11:
+		return EncodingUCS2, 2
+	case dcs&0xC0 == 0x40, dcs&0xF0 == 0x90:
+		// general data coding and messages with user data header
+		return smsAlphabet(dcs & 0x3F), 0
+	case dcs&0xF0 == 0xF0:
+		if dcs&0x04 != 0 {
+			return Encoding8Bit, 0
+		}
+	}
+
+	return EncodingGSM7, 0
+}
+
+// characterSet returns the character set selected with AT+CSCS, which the
+// <str> of AT+CUSD and +CUSD is converted to
+func (t *Atcom) characterSet(attr SerialAttr) (string, error) {
+	com := NewATCommand("AT+CSCS?")
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+
+	if com.Error != nil {
+		return "", com.Error
+	}
+
+	for _, line := range linesWithPrefix(com.Response, "+CSCS:") {
+		if params := splitParams(line); len(params) > 0 {
+			return strings.ToUpper(params[0]), nil
+		}
+	}
+
+	return "GSM", nil
+}
+
+// encodeUSSD converts a request sent with the default alphabet to charset
+func encodeUSSD(text string, charset string) (string, error) {
+	switch charset {
+	case "UCS2":
+		return strings.ToUpper(hex.EncodeToString(ucs2Encode(text))), nil
+	case "HEX":
+		septets, ok := gsm7Encode(text)
+
+		if !ok {
+			return "", errors.New("ussd request is not part of the gsm alphabet: " + text)
+		}
+
+		return strings.ToUpper(hex.EncodeToString(septets)), nil
+	}
+
+	return text, nil
+}
+
+// decodeUSSD decodes the <str> of +CUSD (3GPP TS 27.007). GSM 7-bit text is
+// converted to charset by the modem, 8-bit data and UCS2 are sent as hex.
+func decodeUSSD(payload string, dcs byte, charset string) string {
+	encoding, language := cbsAlphabet(dcs)
+
+	if encoding == EncodingGSM7 && charset != "HEX" && charset != "UCS2" {
+		// the language indication counts characters, not bytes
+		runes := []rune(payload)
+		return string(runes[min(language, len(runes)):])
+	}
+
+	data, err := hex.DecodeString(payload)
+
+	if err != nil {
+		return payload
+	}
+
+	switch encoding {
+	case EncodingUCS2:
+		return ucs2Decode(data[min(language, len(data)):])
+	case Encoding8Bit:
+		return string(data)
+	}
+
+	// HEX holds one septet per octet
+	text := gsm7Decode(data)
+
+	if charset == "UCS2" {
+		text = ucs2Decode(data)
+	}
+
+	runes := []rune(text)
+	return string(runes[min(language, len(runes)):])
+}
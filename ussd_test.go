@@ -0,0 +1,179 @@
+package atcom
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// septetsHex returns text as hex of one GSM 7-bit septet per octet
+func septetsHex(t *testing.T, text string) string {
+	septets, ok := gsm7Encode(text)
+	require.True(t, ok, text)
+
+	return strings.ToUpper(hex.EncodeToString(septets))
+}
+
+func TestParseUSSD(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		charset string
+		status  USSDStatus
+		text    string
+		dcs     int
+	}{
+		{
+			name:    "plain text",
+			line:    `+CUSD: 0,"Balance: 5.00 EUR",15`,
+			charset: "GSM",
+			status:  USSDStatus(0),
+			text:    "Balance: 5.00 EUR",
+			dcs:     15,
+		},
+		{
+			name:    "hex charset",
+			line:    `+CUSD: 1,"2A31303023",15`,
+			charset: "HEX",
+			status:  USSDStatus(1),
+			text:    "*100#",
+			dcs:     15,
+		},
+		{
+			name:    "ucs2 charset",
+			line:    `+CUSD: 0,"002A0031003000300023",15`,
+			charset: "UCS2",
+			status:  USSDStatus(0),
+			text:    "*100#",
+			dcs:     15,
+		},
+		{
+			name:    "ucs2 hex",
+			line:    `+CUSD: 0,"04110430043B0430043D0441003A0020003500200420",72`,
+			charset: "GSM",
+			status:  USSDStatus(0),
+			text:    "Баланс: 5 Р",
+			dcs:     72,
+		},
+		{
+			name:    "ucs2 with language",
+			line:    `+CUSD: 0,"E50600480069",17`,
+			charset: "IRA",
+			status:  USSDStatus(0),
+			text:    "Hi",
+			dcs:     17,
+		},
+		{
+			name:    "8-bit data",
+			line:    `+CUSD: 0,"48656C6C6F",68`,
+			charset: "GSM",
+			status:  USSDStatus(0),
+			text:    "Hello",
+			dcs:     68,
+		},
+		{
+			name:    "status only",
+			line:    "+CUSD: 2",
+			charset: "GSM",
+			status:  USSDStatus(2),
+			dcs:     15,
+		},
+		{
+			name:    "numbers are text",
+			line:    `+CUSD: 0,"1234",15`,
+			charset: "GSM",
+			status:  USSDStatus(0),
+			text:    "1234",
+			dcs:     15,
+		},
+		{
+			name:    "hex digits are text",
+			line:    `+CUSD: 0,"CAFE",15`,
+			charset: "GSM",
+			status:  USSDStatus(0),
+			text:    "CAFE",
+			dcs:     15,
+		},
+		{
+			name:    "lower case charset",
+			line:    `+CUSD: 0,"BEEF12",15`,
+			charset: "ira",
+			status:  USSDStatus(0),
+			text:    "BEEF12",
+			dcs:     15,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := ParseUSSD(tt.line, tt.charset)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.status, response.Status)
+			assert.Equal(t, tt.text, response.Text)
+			assert.Equal(t, tt.dcs, response.DCS)
+		})
+	}
+
+	_, err := ParseUSSD("+CUSD: x", "GSM")
+	assert.Error(t, err)
+}
+
+func TestDecodeUSSDLanguage(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		charset string
+		text    string
+	}{
+		{"hex", septetsHex(t, "fr\rSolde: 5€"), "HEX", "Solde: 5€"},
+		{"ucs2", strings.ToUpper(hex.EncodeToString(ucs2Encode("é€ÄSolde"))), "UCS2", "Solde"},
+		{"converted by the modem", "é€ÄSolde", "GSM", "Solde"},
+		{"language only", "fr", "GSM", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := decodeUSSD(tt.payload, 0x10, tt.charset)
+
+			assert.True(t, utf8.ValidString(text))
+			assert.Equal(t, tt.text, text)
+		})
+	}
+}
+
+func TestStartUSSD(t *testing.T) {
+	modem := newFakeModem()
+	modem.on("AT+CSCS?", "\r\n+CSCS: \"UCS2\"\r\n\r\nOK\r\n")
+	modem.on("AT+CUSD=1,", "\r\nOK\r\n\r\n+CUSD: 1,\"0031002E0020004D0065006E0075\",72\r\n")
+
+	at := NewAtcom(modem, nil)
+	attr := SerialAttr{Port: "/dev/ttyUSB2"}
+
+	ussd, response, err := at.StartUSSD(attr, "*100#")
+	require.NoError(t, err)
+
+	assert.Equal(t, USSDActionRequired, response.Status)
+	assert.Equal(t, "1. Menu", response.Text)
+	assert.Contains(t, modem.commands(), `AT+CUSD=1,"002A0031003000300023",15`)
+
+	// a late answer must not be taken for the answer to the reply
+	modem.emit("\r\n+CUSD: 0,\"004C0061007400650072\",72\r\n")
+	require.Eventually(t, func() bool {
+		return len(ussd.responses) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	modem.on("AT+CUSD=1,", "\r\nOK\r\n\r\n+CUSD: 0,\"0044006F006E0065\",72\r\n")
+
+	response, err = ussd.Reply("1")
+	require.NoError(t, err)
+	assert.Equal(t, "Done", response.Text)
+	assert.Contains(t, modem.commands(), `AT+CUSD=1,"0031",15`)
+
+	require.NoError(t, ussd.Cancel())
+}
@@ -0,0 +1,38 @@
+package atcom
+
+import (
+	"errors"
+	"strings"
+)
+
+// vendor identifies the manufacturer of the modem on attr with AT+CGMI. The
+// result uses the vendor names of SupportedModems.
+func (t *Atcom) vendor(attr SerialAttr) (string, error) {
+	com := NewATCommand("AT+CGMI")
+	com.SerialAttr = attr
+	com = t.SendAT(com)
+
+	if com.Error != nil {
+		return "", com.Error
+	}
+
+	for _, line := range com.Response {
+		upper := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(upper, "AT"), line == "OK":
+			continue
+		case strings.Contains(upper, "QUECTEL"):
+			return "Quectel", nil
+		case strings.Contains(upper, "TELIT"):
+			return "Telit", nil
+		case strings.Contains(upper, "CINTERION"), strings.Contains(upper, "THALES"),
+			strings.Contains(upper, "GEMALTO"), strings.Contains(upper, "SIEMENS"):
+			return "Thales/Cinterion", nil
+		default:
+			return strings.TrimSpace(strings.TrimPrefix(line, "+CGMI:")), nil
+		}
+	}
+
+	return "", errors.New("no manufacturer in response")
+}
@@ -0,0 +1,146 @@
+package atcom
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// ModemEventType is the kind of a ModemEvent
+type ModemEventType int
+
+const (
+	ModemAttached ModemEventType = iota
+	ModemDetached
+)
+
+func (e ModemEventType) String() string {
+	if e == ModemDetached {
+		return "detached"
+	}
+	return "attached"
+}
+
+// ModemEvent reports a supported modem appearing on or leaving the USB or
+// PCI bus
+type ModemEvent struct {
+	Type    ModemEventType
+	VID     string
+	PID     string
+	Vendor  string
+	Product string
+	Path    string // sysfs path of the USB or PCI device, e.g. /devices/platform/scb/usb1/1-1
+}
+
+// intervals of the sysfs polling without and with uevents
+const (
+	watchPollInterval       = 2 * time.Second
+	watchUeventPollInterval = 30 * time.Second
+)
+
+// Watch reports supported modems being attached and detached until ctx is
+// done. Modems present at the start are reported as attached first. Kernel
+// uevents are used where available, sysfs is polled more often otherwise.
+func (t *Atcom) Watch(ctx context.Context) (<-chan ModemEvent, error) {
+	if _, err := os.Stat(t.sysfs("bus", "usb", "devices")); err != nil {
+		return nil, err
+	}
+
+	events := make(chan ModemEvent, 8)
+	changed := make(chan struct{}, 1)
+
+	// uevents do not reach every container, sysfs is polled slowly anyway
+	interval := watchPollInterval
+	if err := listenUevents(ctx, changed); err == nil {
+		interval = watchUeventPollInterval
+	}
+
+	go t.watchModems(ctx, events, changed, interval)
+
+	return events, nil
+}
+
+// watchModems reports the attached and detached modems to events until ctx
+// is done, looking again when changed is signalled or after interval
+func (t *Atcom) watchModems(ctx context.Context, events chan<- ModemEvent, changed <-chan struct{}, interval time.Duration) {
+	defer close(events)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	known := make(map[string]ModemEvent)
+
+	for {
+		// a failed listing, e.g. while devices change, detaches nothing
+		if current, err := t.attachedModems(); err == nil {
+			for path, modem := range known {
+				if _, ok := current[path]; !ok {
+					modem.Type = ModemDetached
+					if !sendModemEvent(ctx, events, modem) {
+						return
+					}
+				}
+			}
+
+			for path, modem := range current {
+				if _, ok := known[path]; !ok {
+					if !sendModemEvent(ctx, events, modem) {
+						return
+					}
+				}
+			}
+
+			known = current
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+func sendModemEvent(ctx context.Context, events chan<- ModemEvent, event ModemEvent) bool {
+	select {
+	case events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// attachedModems returns the supported modems on the USB and PCI bus by
+// sysfs path
+func (t *Atcom) attachedModems() (map[string]ModemEvent, error) {
+	modems := make(map[string]ModemEvent)
+
+	devices, err := t.usbDevices()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if pci, err := t.pciDevices(); err == nil {
+		devices = append(devices, pci...)
+	}
+
+	for _, device := range devices {
+		for _, modem := range SupportedModems() {
+			if modem.VID == device.vid && modem.PID == device.pid {
+				modems[device.path] = ModemEvent{
+					Type:    ModemAttached,
+					VID:     device.vid,
+					PID:     device.pid,
+					Vendor:  modem.Vendor,
+					Product: modem.Product,
+					Path:    device.path,
+				}
+				break
+			}
+		}
+	}
+
+	return modems, nil
+}
@@ -0,0 +1,75 @@
+//go:build linux
+
+package atcom
+
+import (
+	"bytes"
+	"context"
+	"syscall"
+)
+
+// listenUevents signals changed whenever the kernel reports a USB or PCI
+// device or a WWAN port being added or removed, until ctx is done
+func listenUevents(ctx context.Context, changed chan<- struct{}) error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_KOBJECT_UEVENT)
+
+	if err != nil {
+		return err
+	}
+
+	// group 1 receives the kernel events
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: 1}); err != nil {
+		syscall.Close(fd)
+		return err
+	}
+
+	// wake up regularly to notice ctx being done
+	timeout := syscall.Timeval{Sec: 1}
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &timeout); err != nil {
+		syscall.Close(fd)
+		return err
+	}
+
+	go func() {
+		defer syscall.Close(fd)
+
+		buf := make([]byte, 8192)
+
+		for ctx.Err() == nil {
+			n, _, err := syscall.Recvfrom(fd, buf, 0)
+
+			if err != nil || n <= 0 {
+				continue
+			}
+
+			if isModemDeviceEvent(buf[:n]) {
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// isModemDeviceEvent reports whether a uevent adds or removes a USB or PCI
+// device or a WWAN port. Uevents are "action@devpath" followed by KEY=value
+// fields, separated by zero bytes.
+func isModemDeviceEvent(event []byte) bool {
+	fields := bytes.Split(event, []byte{0})
+	action := false
+	device := false
+
+	for _, field := range fields {
+		switch string(field) {
+		case "ACTION=add", "ACTION=remove":
+			action = true
+		case "DEVTYPE=usb_device", "SUBSYSTEM=pci", "SUBSYSTEM=wwan":
+			device = true
+		}
+	}
+
+	return action && device
+}
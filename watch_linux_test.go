@@ -0,0 +1,22 @@
+//go:build linux
+
+package atcom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsModemDeviceEvent(t *testing.T) {
+	event := func(fields ...string) []byte {
+		return []byte(strings.Join(fields, "\x00"))
+	}
+
+	assert.True(t, isModemDeviceEvent(event("add@/devices/platform/scb/usb1/1-1", "ACTION=add", "DEVTYPE=usb_device", "SUBSYSTEM=usb")))
+	assert.True(t, isModemDeviceEvent(event("remove@/devices/pci0000:00/0000:00:1c.0/0000:01:00.0", "ACTION=remove", "SUBSYSTEM=pci")))
+	assert.True(t, isModemDeviceEvent(event("add@/devices/pci0000:00/0000:00:1c.0/0000:01:00.0/mhi0/wwan/wwan0/wwan0at0", "ACTION=add", "SUBSYSTEM=wwan")))
+	assert.False(t, isModemDeviceEvent(event("add@/devices/platform/scb/usb1/1-1/1-1:1.2", "ACTION=add", "DEVTYPE=usb_interface", "SUBSYSTEM=usb")))
+	assert.False(t, isModemDeviceEvent(event("change@/devices/pci0000:00/0000:00:1c.0/0000:01:00.0", "ACTION=change", "SUBSYSTEM=pci")))
+}
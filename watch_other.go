@@ -0,0 +1,13 @@
+//go:build !linux
+
+package atcom
+
+import (
+	"context"
+	"errors"
+)
+
+// listenUevents is only available on Linux, Watch polls sysfs otherwise
+func listenUevents(ctx context.Context, changed chan<- struct{}) error {
+	return errors.New("uevents are not supported")
+}
@@ -0,0 +1,101 @@
+package atcom
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachedModems(t *testing.T) {
+	root := fakeSysfs(t)
+
+	at := NewAtcom(nil, nil)
+	at.SetSysfsRoot(root)
+
+	modems, err := at.attachedModems()
+	require.NoError(t, err)
+
+	// the root hub is not a modem
+	assert.Equal(t, map[string]ModemEvent{
+		"/devices/platform/scb/usb1/1-1": {
+			Type:    ModemAttached,
+			VID:     "2c7c",
+			PID:     "0125",
+			Vendor:  "Quectel",
+			Product: "EC25",
+			Path:    "/devices/platform/scb/usb1/1-1",
+		},
+	}, modems)
+
+	require.NoError(t, os.RemoveAll(filepath.Join(root, "bus")))
+
+	_, err = at.attachedModems()
+	assert.Error(t, err)
+}
+
+func TestWatchModems(t *testing.T) {
+	root := fakeSysfs(t)
+
+	at := NewAtcom(nil, nil)
+	at.SetSysfsRoot(root)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan ModemEvent)
+	changed := make(chan struct{})
+	go at.watchModems(ctx, events, changed, time.Hour)
+
+	next := func() ModemEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event")
+			return ModemEvent{}
+		}
+	}
+	assertNoEvent := func() {
+		select {
+		case event := <-events:
+			t.Fatalf("unexpected event %v", event)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	// modems present at the start are reported first
+	event := next()
+	assert.Equal(t, ModemAttached, event.Type)
+	assert.Equal(t, "/devices/platform/scb/usb1/1-1", event.Path)
+
+	// a listing failing for a moment does not detach the modem
+	devices := filepath.Join(root, "bus", "usb", "devices")
+	require.NoError(t, os.Rename(devices, devices+".moved"))
+	changed <- struct{}{}
+	assertNoEvent()
+
+	require.NoError(t, os.Rename(devices+".moved", devices))
+	changed <- struct{}{}
+	assertNoEvent()
+
+	require.NoError(t, os.Remove(filepath.Join(devices, "1-1")))
+	changed <- struct{}{}
+
+	event = next()
+	assert.Equal(t, ModemDetached, event.Type)
+	assert.Equal(t, "EC25", event.Product)
+
+	cancel()
+
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok)
+	case <-time.After(2 * time.Second):
+		t.Fatal("events not closed")
+	}
+}
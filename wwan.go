@@ -0,0 +1,109 @@
+package atcom
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// wwanTypeRoles are the roles of the WWAN port types of the kernel. AT ports
+// other than the AT interface of the modem are additional AT ports.
+var wwanTypeRoles = map[string]PortRole{
+	"AT":       RoleAT2,
+	"NMEA":     RoleNMEA,
+	"QCDM":     RoleDIAG,
+	"FIREHOSE": RoleDIAG,
+	"QMI":      RoleQMI,
+	"MBIM":     RoleMBIM,
+}
+
+// wwanPorts lists the ports of PCIe modems in /sys/class/wwan, e.g.
+// /dev/wwan0at0. The vendor and product ids are the PCI ids of the modem and
+// the interface is the port name without the device, e.g. at0 or mbim0.
+func (t *Atcom) wwanPorts() ([]map[string]string, error) {
+	entries, err := os.ReadDir(t.sysfs("class", "wwan"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	ports := make([]map[string]string, 0)
+
+	for _, entry := range entries {
+		dir, err := filepath.EvalSymlinks(t.sysfs("class", "wwan", entry.Name()))
+
+		// the wwan device itself has no device node, only its ports
+		if err != nil || readSysfs(filepath.Join(dir, "dev")) == "" {
+			continue
+		}
+
+		// ports are children of the wwan device, e.g. wwan0/wwan0at0
+		name := strings.TrimPrefix(entry.Name(), filepath.Base(filepath.Dir(dir)))
+
+		if name == entry.Name() || name == "" {
+			continue
+		}
+
+		portType := readSysfs(filepath.Join(dir, "type"))
+		if portType == "" {
+			portType = strings.ToUpper(strings.TrimRight(name, "0123456789"))
+		}
+
+		// the PCI function is one of the parents, behind the MHI controller
+		device := filepath.Dir(dir)
+		for readSysfs(filepath.Join(device, "vendor")) == "" && device != filepath.Dir(device) {
+			device = filepath.Dir(device)
+		}
+
+		vid := pciID(readSysfs(filepath.Join(device, "vendor")))
+		pid := pciID(readSysfs(filepath.Join(device, "device")))
+
+		if vid == "" || pid == "" {
+			continue
+		}
+
+		ports = append(ports, map[string]string{
+			"port":       "/dev/" + entry.Name(),
+			"vendor_id":  vid,
+			"product_id": pid,
+			"interface":  name,
+			"type":       portType,
+			"path":       t.relativeSysfs(device),
+		})
+	}
+
+	return ports, nil
+}
+
+// pciDevices lists the PCI devices in /sys/bus/pci/devices. Only the ids and
+// the path are set, PCI devices have no descriptive strings.
+func (t *Atcom) pciDevices() ([]usbDevice, error) {
+	entries, err := os.ReadDir(t.sysfs("bus", "pci", "devices"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([]usbDevice, 0)
+
+	for _, entry := range entries {
+		dir, err := filepath.EvalSymlinks(t.sysfs("bus", "pci", "devices", entry.Name()))
+
+		if err != nil {
+			continue
+		}
+
+		devices = append(devices, usbDevice{
+			vid:  pciID(readSysfs(filepath.Join(dir, "vendor"))),
+			pid:  pciID(readSysfs(filepath.Join(dir, "device"))),
+			path: t.relativeSysfs(dir),
+		})
+	}
+
+	return devices, nil
+}
+
+// pciID converts a PCI id attribute like 0x17cb to the lsusb style 17cb
+func pciID(id string) string {
+	return strings.ToLower(strings.TrimPrefix(id, "0x"))
+}
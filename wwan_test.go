@@ -0,0 +1,90 @@
+package atcom
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWWANSysfs builds a sysfs tree with a Quectel RM520N on the PCI bus
+// and returns its root
+func fakeWWANSysfs(t *testing.T) string {
+	root := t.TempDir()
+
+	write := func(path string, content string) {
+		path = filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content+"\n"), 0o644))
+	}
+	link := func(path string, target string) {
+		path = filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.Symlink(filepath.Join(root, target), path))
+	}
+
+	device := "devices/pci0000:00/0000:00:1c.0/0000:01:00.0"
+	wwan := device + "/mhi0/wwan/wwan0"
+
+	write(device+"/vendor", "0x17cb")
+	write(device+"/device", "0x0308")
+	link("bus/pci/devices/0000:01:00.0", device)
+
+	write(wwan+"/uevent", "DEVTYPE=wwan_dev")
+	link("class/wwan/wwan0", wwan)
+
+	// the type is missing on older kernels
+	for name, portType := range map[string]string{"at0": "AT", "at1": "AT", "qcdm0": "QCDM", "mbim0": "MBIM", "firehose0": "FIREHOSE", "nmea0": ""} {
+		write(wwan+"/wwan0"+name+"/dev", "234:0")
+		if portType != "" {
+			write(wwan+"/wwan0"+name+"/type", portType)
+		}
+		link("class/wwan/wwan0"+name, wwan+"/wwan0"+name)
+	}
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "bus", "usb", "devices"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "class", "tty"), 0o755))
+
+	return root
+}
+
+func TestDecidePortWWAN(t *testing.T) {
+	at := NewAtcom(nil, nil)
+	at.SetSysfsRoot(fakeWWANSysfs(t))
+
+	modem, err := at.DecidePort()
+	require.NoError(t, err)
+
+	assert.Equal(t, "RM520N PCIe", modem.Product)
+	assert.Equal(t, "/dev/wwan0at0", modem.Port)
+	assert.Equal(t, "/devices/pci0000:00/0000:00:1c.0/0000:01:00.0", modem.USBPath)
+	assert.Equal(t, []DetectedPort{
+		{Port: "/dev/wwan0at0", Interface: "at0", Type: "AT", Role: RoleAT},
+		{Port: "/dev/wwan0at1", Interface: "at1", Type: "AT", Role: RoleAT2},
+		{Port: "/dev/wwan0firehose0", Interface: "firehose0", Type: "FIREHOSE", Role: RoleDIAG},
+		{Port: "/dev/wwan0mbim0", Interface: "mbim0", Type: "MBIM", Role: RoleMBIM},
+		{Port: "/dev/wwan0nmea0", Interface: "nmea0", Type: "NMEA", Role: RoleNMEA},
+		{Port: "/dev/wwan0qcdm0", Interface: "qcdm0", Type: "QCDM", Role: RoleDIAG},
+	}, modem.Interfaces)
+}
+
+func TestAttachedModemsWWAN(t *testing.T) {
+	at := NewAtcom(nil, nil)
+	at.SetSysfsRoot(fakeWWANSysfs(t))
+
+	modems, err := at.attachedModems()
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]ModemEvent{
+		"/devices/pci0000:00/0000:00:1c.0/0000:01:00.0": {
+			Type:    ModemAttached,
+			VID:     "17cb",
+			PID:     "0308",
+			Vendor:  "Quectel",
+			Product: "RM520N PCIe",
+			Path:    "/devices/pci0000:00/0000:00:1c.0/0000:01:00.0",
+		},
+	}, modems)
+}